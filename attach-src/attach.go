@@ -0,0 +1,88 @@
+package attach
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"language-analysis/config"
+	fetcher "language-analysis/fetcher-src"
+)
+
+const Driver = "sqlite3-attached"
+
+var databases = []struct {
+	schema   string
+	filename string
+}{
+	{"fetcher", "fetcher.db"},
+	{"registry", "speakers.db"},
+}
+
+func init() {
+	sql.Register(Driver, &sqlite3.SQLiteDriver{
+		ConnectHook: attach,
+	})
+}
+
+func attach(conn *sqlite3.SQLiteConn) error {
+	for _, d := range databases {
+		filename := config.Dir() + "/" + d.filename
+		if _, err := os.Stat(filename); err != nil {
+			continue
+		}
+		if _, err := conn.Exec("ATTACH DATABASE ? AS "+d.schema, []driver.Value{"file:" + filename + "?mode=ro"}); err != nil {
+			return fmt.Errorf("Failed to attach %s: %v", filename, err)
+		}
+	}
+	return nil
+}
+
+func Attached(db *sql.DB, schema string) (bool, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_database_list WHERE name = ?", schema).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func Require(db *sql.DB, schema string) error {
+	attached, err := Attached(db, schema)
+	if err != nil {
+		return err
+	}
+	if attached {
+		return nil
+	}
+	for _, d := range databases {
+		if d.schema == schema {
+			return fmt.Errorf("Missing %s/%s to attach as %s", config.Dir(), d.filename, schema)
+		}
+	}
+	return fmt.Errorf("Unknown attached database: %s", schema)
+}
+
+func FilesAfter(db *sql.DB, since time.Time, afterFileID int64, limit int) ([]fetcher.File, error) {
+	if err := Require(db, "fetcher"); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT "+fetcher.FileColumns+" FROM fetcher.files WHERE fetchTimestamp > ? OR (fetchTimestamp = ? AND fileID > ?) ORDER BY fetchTimestamp ASC, fileID ASC LIMIT ?", since.Format(time.DateTime), since.Format(time.DateTime), afterFileID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []fetcher.File{}
+	for rows.Next() {
+		file, err := fetcher.ScanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
@@ -1,114 +1,337 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
-var options = map[string]string{
-	"dir": "./data",
-
-	"fetcher-sleep":        "15s",
-	"thank-collect-count":  "50",
-	"phrase-collect-count": "500",
+type Flag struct {
+	Name    string
+	Value   any
+	Usage   string
+	Choices []string
 }
 
 type Command struct {
-	Name string
-	Run  func() error
-}
-
-func Run(commands []Command, defaultCommand Command, initialize func() error, processArg func(string) (bool, error)) {
-	run := false
-	for _, arg := range os.Args[1:] {
-		if len(arg) > 0 && arg[0] == '-' {
-			if eq := strings.Index(arg, "="); eq > 0 {
-				options[arg[1:eq]] = arg[eq+1:]
-			} else {
-				options[arg[1:eq]] = ""
+	Name     string
+	Args     string
+	Usage    string
+	Flags    []Flag
+	Commands []Command
+	Default  string
+	Init     func() error
+	Run      func() error
+}
+
+type UsageError struct {
+	message string
+}
+
+func (e UsageError) Error() string {
+	return e.message
+}
+
+func Usagef(format string, a ...any) error {
+	return UsageError{fmt.Sprintf(format, a...)}
+}
+
+type option struct {
+	flag   Flag
+	value  any
+	source string
+}
+
+var schema = map[string]Flag{}
+var options = map[string]option{}
+var flagValues = map[string]string{}
+var args []string
+
+func Main(root Command) {
+	if err := collectSchema(root); err != nil {
+		panic(err)
+	}
+	os.Exit(run([]string{root.Name}, root, os.Args[1:], nil, nil))
+}
+
+func collectSchema(c Command) error {
+	for _, f := range c.Flags {
+		switch f.Value.(type) {
+		case string, int, time.Duration:
+		default:
+			return fmt.Errorf("Unsupported type %T for option %s", f.Value, f.Name)
+		}
+		if existing, ok := schema[f.Name]; ok && (existing.Value != f.Value || !slices.Equal(existing.Choices, f.Choices)) {
+			return fmt.Errorf("Conflicting declarations of option %s", f.Name)
+		}
+		schema[f.Name] = f
+	}
+	for _, sub := range c.Commands {
+		if err := collectSchema(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run(path []string, c Command, arguments []string, inherited []Flag, inits []func() error) int {
+	name := strings.Join(path, " ")
+	flags := append(slices.Clone(inherited), c.Flags...)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	for _, f := range flags {
+		switch v := f.Value.(type) {
+		case string:
+			fs.String(f.Name, v, f.Usage)
+		case int:
+			fs.Int(f.Name, v, f.Usage)
+		case time.Duration:
+			fs.Duration(f.Name, v, f.Usage)
+		}
+	}
+
+	if err := fs.Parse(arguments); errors.Is(err, flag.ErrHelp) {
+		usage(os.Stdout, name, c, fs)
+		return 0
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		usage(os.Stderr, name, c, fs)
+		return 2
+	}
+	fs.Visit(func(f *flag.Flag) {
+		flagValues[f.Name] = f.Value.String()
+	})
+	if c.Init != nil {
+		inits = append(inits, c.Init)
+	}
+
+	rest := fs.Args()
+	if len(c.Commands) > 0 {
+		subcommand := c.Default
+		if len(rest) > 0 {
+			subcommand, rest = rest[0], rest[1:]
+		}
+		for _, sub := range c.Commands {
+			if sub.Name == subcommand {
+				return run(append(path, sub.Name), sub, rest, flags, inits)
 			}
-			continue
-		}
-		run = false
-		for _, c := range commands {
-			if arg == c.Name {
-				run = true
-				if initialize != nil {
-					if err := initialize(); err != nil {
-						fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], arg, err)
-						os.Exit(1)
-					}
-				}
-				if err := c.Run(); err != nil {
-					fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], arg, err)
-					os.Exit(1)
-				}
-				break
+		}
+		if subcommand != "" || c.Run == nil {
+			if subcommand != "" {
+				fmt.Fprintf(os.Stderr, "%s: unknown command %s\n", name, subcommand)
 			}
+			usage(os.Stderr, name, c, fs)
+			return 2
 		}
-		if !run && processArg != nil {
-			processed, err := processArg(arg)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], arg, err)
-				os.Exit(1)
+	}
+
+	args = rest
+	if err := load(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 2
+	}
+	for _, init := range inits {
+		if err := init(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return 1
+		}
+	}
+	if err := c.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		var usageErr UsageError
+		if errors.As(err, &usageErr) {
+			usage(os.Stderr, name, c, fs)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+func usage(out io.Writer, name string, c Command, fs *flag.FlagSet) {
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) {
+		hasFlags = true
+	})
+	line := name
+	if hasFlags {
+		line += " [flags]"
+	}
+	if len(c.Commands) > 0 {
+		line += " <command>"
+	}
+	if c.Args != "" {
+		line += " " + c.Args
+	}
+	fmt.Fprintf(out, "Usage: %s\n", line)
+	if c.Usage != "" {
+		fmt.Fprintf(out, "\n%s\n", c.Usage)
+	}
+	if len(c.Commands) > 0 {
+		fmt.Fprintf(out, "\nCommands:\n")
+		for _, sub := range c.Commands {
+			marker := ""
+			if sub.Name == c.Default {
+				marker = " (default)"
 			}
-			run = processed
+			fmt.Fprintf(out, "  %-14s %s%s\n", sub.Name, sub.Usage, marker)
+		}
+	}
+	if hasFlags {
+		fmt.Fprintf(out, "\nFlags:\n")
+		fs.SetOutput(out)
+		fs.PrintDefaults()
+		fs.SetOutput(io.Discard)
+	}
+}
+
+func envName(name string) string {
+	return "LA_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func load() error {
+	for name, f := range schema {
+		options[name] = option{flag: f, value: f.Value, source: "default"}
+	}
+
+	dir := options["dir"].value.(string)
+	if value, ok := os.LookupEnv(envName("dir")); ok {
+		dir = value
+	}
+	if value, ok := flagValues["dir"]; ok {
+		dir = value
+	}
+	filename := dir + "/language-analysis.toml"
+	if _, err := os.Stat(filename); err == nil {
+		values := map[string]any{}
+		if _, err := toml.DecodeFile(filename, &values); err != nil {
+			return fmt.Errorf("Failed to read %s: %v", filename, err)
 		}
-		if !run {
-			fmt.Fprintf(os.Stderr, "%s: unknown command %s.  Available commands:", os.Args[0])
-			for _, c := range commands {
-				fmt.Fprintf(os.Stderr, " %s", c.Name)
+		for name, value := range values {
+			if err := set(name, value, filename); err != nil {
+				return err
 			}
-			fmt.Fprintf(os.Stderr, "\n")
-			os.Exit(1)
 		}
 	}
-	if !run {
-		if initialize != nil {
-			if err := initialize(); err != nil {
-				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
-				os.Exit(1)
+
+	for name := range schema {
+		if value, ok := os.LookupEnv(envName(name)); ok {
+			if err := set(name, value, envName(name)); err != nil {
+				return err
 			}
 		}
-		if err := defaultCommand.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
-			os.Exit(1)
+	}
+
+	for name, value := range flagValues {
+		if err := set(name, value, "-"+name); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
-func Dir() string {
-	return String("dir", "./data")
-}
+func set(name string, value any, source string) error {
+	f, ok := schema[name]
+	if !ok {
+		return fmt.Errorf("Unknown option %s in %s", name, source)
+	}
 
-func String(name string, defaultValue string) string {
-	if value, ok := options[name]; ok {
-		return value
-	} else {
-		return defaultValue
+	var v any
+	switch f.Value.(type) {
+	case string:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("Invalid value for %s in %s: expected a string", name, source)
+		}
+		v = s
+	case int:
+		switch n := value.(type) {
+		case int64:
+			v = int(n)
+		case string:
+			i, err := strconv.Atoi(n)
+			if err != nil {
+				return fmt.Errorf("Invalid value for %s in %s: expected an integer, got %q", name, source, n)
+			}
+			v = i
+		default:
+			return fmt.Errorf("Invalid value for %s in %s: expected an integer", name, source)
+		}
+	case time.Duration:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("Invalid value for %s in %s: expected a duration such as \"15s\"", name, source)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("Invalid value for %s in %s: expected a duration such as \"15s\", got %q", name, source, s)
+		}
+		v = d
 	}
+
+	if s, ok := v.(string); ok && len(f.Choices) > 0 && !slices.Contains(f.Choices, s) {
+		return fmt.Errorf("Invalid value for %s in %s: %q is not one of %s", name, source, s, strings.Join(f.Choices, ", "))
+	}
+
+	options[name] = option{flag: f, value: v, source: source}
+	return nil
 }
 
-func Int(name string, defaultValue int) (int, error) {
-	if value, ok := options[name]; ok {
-		var v int
-		_, err := fmt.Sscanf(value, "%d", &v)
-		return v, err
-	} else {
-		return defaultValue, nil
+func value(name string) any {
+	o, ok := options[name]
+	if !ok {
+		panic(fmt.Sprintf("Undeclared option %s", name))
 	}
+	return o.value
+}
+
+func Args() []string {
+	return args
 }
 
-func Duration(name string, defaultValue time.Duration) (time.Duration, error) {
-	if value, ok := options[name]; ok {
-		return time.ParseDuration(value)
-	} else {
-		return defaultValue, nil
+func Dir() string {
+	return String("dir")
+}
+
+func String(name string) string {
+	return value(name).(string)
+}
+
+func Int(name string) int {
+	return value(name).(int)
+}
+
+func Duration(name string) time.Duration {
+	return value(name).(time.Duration)
+}
+
+func ShowCommand() error {
+	names := []string{}
+	for name := range options {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(out, "option\tvalue\tsource\t\n")
+	for _, name := range names {
+		o := options[name]
+		if s, ok := o.value.(string); ok {
+			fmt.Fprintf(out, "%s\t%q\t%s\t\n", name, s, o.source)
+		} else {
+			fmt.Fprintf(out, "%s\t%v\t%s\t\n", name, o.value, o.source)
+		}
 	}
+	return out.Flush()
 }
 
 func ReadConfig(filename string, conf any) error {
@@ -0,0 +1,142 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+type Kind int
+
+const (
+	String Kind = iota
+	Int64
+	Date
+)
+
+type Column struct {
+	Name     string
+	Kind     Kind
+	Nullable bool
+}
+
+type Writer interface {
+	Write(values ...any) error
+	Close() error
+}
+
+func NewWriter(format string, out io.Writer, columns []Column) (Writer, error) {
+	switch format {
+	case "csv":
+		w := &csvWriter{out: csv.NewWriter(out)}
+		header := []string{}
+		for _, column := range columns {
+			header = append(header, column.Name)
+		}
+		if err := w.out.Write(header); err != nil {
+			return nil, err
+		}
+		return w, nil
+	case "jsonl":
+		return &jsonlWriter{out: out, columns: columns}, nil
+	case "parquet":
+		return newParquetWriter(out, columns), nil
+	default:
+		return nil, fmt.Errorf("Unknown export format: %s", format)
+	}
+}
+
+type output struct {
+	*bufio.Writer
+	file *os.File
+}
+
+func (o output) Close() error {
+	if err := o.Flush(); err != nil {
+		return err
+	}
+	if o.file != os.Stdout {
+		return o.file.Close()
+	}
+	return nil
+}
+
+func Create(filename string) (io.WriteCloser, error) {
+	if filename == "" || filename == "-" {
+		return output{bufio.NewWriter(os.Stdout), os.Stdout}, nil
+	}
+	fd, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return output{bufio.NewWriter(fd), fd}, nil
+}
+
+func format(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return v.Format(time.DateOnly)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type csvWriter struct {
+	out *csv.Writer
+}
+
+func (w *csvWriter) Write(values ...any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = format(value)
+	}
+	return w.out.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	w.out.Flush()
+	return w.out.Error()
+}
+
+type jsonlWriter struct {
+	out     io.Writer
+	columns []Column
+}
+
+func (w *jsonlWriter) Write(values ...any) error {
+	line := []byte{'{'}
+	for i, column := range w.columns {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = strconv.AppendQuote(line, column.Name)
+		line = append(line, ':')
+		var value any = values[i]
+		if column.Kind == Date && value != nil {
+			value = format(values[i])
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		line = append(line, data...)
+	}
+	line = append(line, '}', '\n')
+	_, err := w.out.Write(line)
+	return err
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,295 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const rowGroupSize = 65536
+
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	convertedUTF8 = 0
+	convertedDate = 6
+
+	encodingPlain = 0
+	encodingRLE   = 3
+)
+
+type columnChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+type rowGroup struct {
+	chunks []columnChunk
+	rows   int64
+	size   int64
+}
+
+type parquetWriter struct {
+	out     io.Writer
+	offset  int64
+	err     error
+	columns []Column
+	buffers []bytes.Buffer
+	defined [][]bool
+	rows    int64
+	total   int64
+	groups  []rowGroup
+}
+
+func newParquetWriter(out io.Writer, columns []Column) *parquetWriter {
+	w := &parquetWriter{
+		out:     out,
+		columns: columns,
+		buffers: make([]bytes.Buffer, len(columns)),
+		defined: make([][]bool, len(columns)),
+	}
+	w.write([]byte("PAR1"))
+	return w
+}
+
+func (w *parquetWriter) write(data []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.out.Write(data)
+	w.offset += int64(n)
+	w.err = err
+}
+
+func (w *parquetWriter) Write(values ...any) error {
+	for i, column := range w.columns {
+		buf := &w.buffers[i]
+		if column.Nullable {
+			w.defined[i] = append(w.defined[i], values[i] != nil)
+			if values[i] == nil {
+				continue
+			}
+		}
+		switch column.Kind {
+		case String:
+			s := format(values[i])
+			binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+			buf.WriteString(s)
+		case Int64:
+			binary.Write(buf, binary.LittleEndian, values[i].(int64))
+		case Date:
+			binary.Write(buf, binary.LittleEndian, int32(values[i].(time.Time).Unix()/(24*60*60)))
+		}
+	}
+	w.rows++
+	if w.rows == rowGroupSize {
+		w.flush()
+	}
+	return w.err
+}
+
+func (w *parquetWriter) flush() {
+	group := rowGroup{rows: w.rows}
+	for i, column := range w.columns {
+		data := w.buffers[i].Bytes()
+		if column.Nullable {
+			data = append(definitionLevels(w.defined[i]), data...)
+			w.defined[i] = w.defined[i][:0]
+		}
+
+		header := newThrift()
+		header.i32(1, 0)
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.begin(5)
+		header.i32(1, int32(w.rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.end()
+		header.end()
+
+		chunk := columnChunk{offset: w.offset, values: w.rows}
+		w.write(header.buf.Bytes())
+		w.write(data)
+		chunk.size = w.offset - chunk.offset
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+		w.buffers[i].Reset()
+	}
+	w.groups = append(w.groups, group)
+	w.total += w.rows
+	w.rows = 0
+}
+
+// definitionLevels encodes whether each value of an optional column is
+// present as bit-packed runs with a bit width of 1, preceded by their
+// length as the data page format requires.
+func definitionLevels(defined []bool) []byte {
+	groups := (len(defined) + 7) / 8
+	levels := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	packed := make([]byte, groups)
+	for i, d := range defined {
+		if d {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	levels = append(levels, packed...)
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))), levels...)
+}
+
+func (c Column) parquetType() (int32, int32) {
+	switch c.Kind {
+	case Int64:
+		return parquetInt64, -1
+	case Date:
+		return parquetInt32, convertedDate
+	default:
+		return parquetByteArray, convertedUTF8
+	}
+}
+
+func (w *parquetWriter) Close() error {
+	if w.rows > 0 {
+		w.flush()
+	}
+
+	meta := newThrift()
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(w.columns)+1)
+	meta.element()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(w.columns)))
+	meta.end()
+	for _, column := range w.columns {
+		typ, converted := column.parquetType()
+		meta.element()
+		meta.i32(1, typ)
+		if column.Nullable {
+			meta.i32(3, 1)
+		} else {
+			meta.i32(3, 0)
+		}
+		meta.binary(4, column.Name)
+		if converted >= 0 {
+			meta.i32(6, converted)
+		}
+		meta.end()
+	}
+	meta.i64(3, w.total)
+	meta.list(4, thriftStruct, len(w.groups))
+	for _, group := range w.groups {
+		meta.element()
+		meta.list(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			typ, _ := w.columns[i].parquetType()
+			meta.element()
+			meta.i64(2, chunk.offset)
+			meta.begin(3)
+			meta.i32(1, typ)
+			meta.list(2, thriftI32, 2)
+			meta.varint(zigzag(encodingPlain))
+			meta.varint(zigzag(encodingRLE))
+			meta.list(3, thriftBinary, 1)
+			meta.varint(uint64(len(w.columns[i].Name)))
+			meta.buf.WriteString(w.columns[i].Name)
+			meta.i32(4, 0)
+			meta.i64(5, chunk.values)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.end()
+			meta.end()
+		}
+		meta.i64(2, group.size)
+		meta.i64(3, group.rows)
+		meta.end()
+	}
+	meta.binary(6, "language-analysis")
+	meta.end()
+
+	w.write(meta.buf.Bytes())
+	w.write(binary.LittleEndian.AppendUint32(nil, uint32(meta.buf.Len())))
+	w.write([]byte("PAR1"))
+	return w.err
+}
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+type thrift struct {
+	buf  bytes.Buffer
+	last []int16
+}
+
+func newThrift() *thrift {
+	return &thrift{last: []int16{0}}
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (t *thrift) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (t *thrift) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thrift) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thrift) list(id int16, typ byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | typ)
+	} else {
+		t.buf.WriteByte(0xf0 | typ)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thrift) begin(id int16) {
+	t.field(id, thriftStruct)
+	t.last = append(t.last, 0)
+}
+
+func (t *thrift) element() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thrift) end() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
@@ -0,0 +1,361 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic(fmt.Sprintf("Bad varint at %d", r.pos))
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 3, 4, thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := []any{}
+		for range size {
+			list = append(list, r.value(header&0x0f))
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("Unknown thrift type %d at %d", typ, r.pos))
+}
+
+func (r *thriftReader) readStruct() map[int64]any {
+	fields := map[int64]any{}
+	var last int64
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int64(header>>4)
+		if header>>4 == 0 {
+			id = r.varint()
+		}
+		last = id
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+func readLevels(t *testing.T, r *thriftReader, count int) []bool {
+	t.Helper()
+	length := int(binary.LittleEndian.Uint32(r.data[r.pos:]))
+	r.pos += 4
+	end := r.pos + length
+	defined := []bool{}
+	for r.pos < end {
+		header := r.uvarint()
+		if header&1 == 0 {
+			value := r.byte() == 1
+			for range header >> 1 {
+				defined = append(defined, value)
+			}
+			continue
+		}
+		for range header >> 1 {
+			b := r.byte()
+			for bit := range 8 {
+				defined = append(defined, b&(1<<bit) != 0)
+			}
+		}
+	}
+	if r.pos != end || len(defined) < count {
+		t.Fatalf("Decoded %d definition levels ending at %d, want %d ending at %d", len(defined), r.pos, count, end)
+	}
+	return defined[:count]
+}
+
+func readParquet(t *testing.T, data []byte) ([]string, [][]any) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("Missing PAR1 magic")
+	}
+	length := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[:len(data)-8], pos: len(data) - 8 - length}
+	meta := footer.readStruct()
+	if footer.pos != len(data)-8 {
+		t.Fatalf("Footer ends at %d, want %d", footer.pos, len(data)-8)
+	}
+
+	schema := meta[2].([]any)
+	root := schema[0].(map[int64]any)
+	if root[5].(int64) != int64(len(schema)-1) {
+		t.Fatalf("Root has %d children, schema has %d columns", root[5], len(schema)-1)
+	}
+	names := []string{}
+	for _, element := range schema[1:] {
+		names = append(names, element.(map[int64]any)[4].(string))
+	}
+
+	rows := [][]any{}
+	for _, g := range meta[4].([]any) {
+		group := g.(map[int64]any)
+		numRows := int(group[3].(int64))
+		columns := [][]any{}
+		var groupSize int64
+		for i, c := range group[1].([]any) {
+			chunk := c.(map[int64]any)
+			column := chunk[3].(map[int64]any)
+			element := schema[i+1].(map[int64]any)
+			if column[1] != element[1] || !reflect.DeepEqual(column[3], []any{names[i]}) {
+				t.Fatalf("Column chunk %d does not match schema element %v", i, element)
+			}
+			if column[4].(int64) != 0 || column[5].(int64) != int64(numRows) {
+				t.Fatalf("Column chunk %d has codec %d and %d values for %d rows", i, column[4], column[5], numRows)
+			}
+
+			offset := int(column[9].(int64))
+			if chunk[2].(int64) != int64(offset) {
+				t.Fatalf("Column chunk %d file_offset %d, data_page_offset %d", i, chunk[2], offset)
+			}
+			page := &thriftReader{data: data, pos: offset}
+			header := page.readStruct()
+			dataPage := header[5].(map[int64]any)
+			if header[1].(int64) != 0 || header[2] != header[3] || dataPage[1].(int64) != int64(numRows) || dataPage[2].(int64) != encodingPlain {
+				t.Fatalf("Unexpected page header %v", header)
+			}
+			end := page.pos + int(header[2].(int64))
+			if int64(end-offset) != column[6].(int64) || column[6] != column[7] {
+				t.Fatalf("Column chunk %d is %d bytes, metadata says %d", i, end-offset, column[6])
+			}
+			groupSize += int64(end - offset)
+
+			defined := make([]bool, numRows)
+			for r := range defined {
+				defined[r] = true
+			}
+			if element[3].(int64) == 1 {
+				defined = readLevels(t, page, numRows)
+			}
+			values := make([]any, 0, numRows)
+			for len(values) < numRows && !defined[len(values)] {
+				values = append(values, nil)
+			}
+			for page.pos < end {
+				switch column[1].(int64) {
+				case parquetByteArray:
+					n := int(binary.LittleEndian.Uint32(data[page.pos:]))
+					values = append(values, string(data[page.pos+4:page.pos+4+n]))
+					page.pos += 4 + n
+				case parquetInt64:
+					values = append(values, int64(binary.LittleEndian.Uint64(data[page.pos:])))
+					page.pos += 8
+				case parquetInt32:
+					days := int32(binary.LittleEndian.Uint32(data[page.pos:]))
+					values = append(values, time.Unix(int64(days)*24*60*60, 0).UTC())
+					page.pos += 4
+				}
+				for len(values) < numRows && !defined[len(values)] {
+					values = append(values, nil)
+				}
+			}
+			if page.pos != end || len(values) != numRows {
+				t.Fatalf("Column chunk %d decoded %d values ending at %d, want %d ending at %d", i, len(values), page.pos, numRows, end)
+			}
+			columns = append(columns, values)
+		}
+		if group[2].(int64) != groupSize {
+			t.Fatalf("Row group total_byte_size %d, want %d", group[2], groupSize)
+		}
+		for r := range numRows {
+			row := []any{}
+			for _, values := range columns {
+				row = append(row, values[r])
+			}
+			rows = append(rows, row)
+		}
+	}
+	if meta[3].(int64) != int64(len(rows)) {
+		t.Fatalf("num_rows %d, decoded %d", meta[3], len(rows))
+	}
+	return names, rows
+}
+
+var testColumns = []Column{
+	{Name: "date", Kind: Date},
+	{Name: "fileID", Kind: Int64},
+	{Name: "speaker", Kind: String},
+}
+
+func testRow(i int) []any {
+	date := time.Date(1965, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i%30000)
+	return []any{date, int64(i) - 5, fmt.Sprintf("speaker “%d”", i%97)}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	for _, count := range []int{0, 1, 3, rowGroupSize, 2*rowGroupSize + 17} {
+		t.Run(fmt.Sprintf("%d rows", count), func(t *testing.T) {
+			out := bytes.Buffer{}
+			w, err := NewWriter("parquet", &out, testColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range count {
+				if err := w.Write(testRow(i)...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			names, rows := readParquet(t, out.Bytes())
+			if !reflect.DeepEqual(names, []string{"date", "fileID", "speaker"}) {
+				t.Errorf("Columns %q", names)
+			}
+			if len(rows) != count {
+				t.Fatalf("Read %d rows, wrote %d", len(rows), count)
+			}
+			for i, row := range rows {
+				if want := testRow(i); !reflect.DeepEqual(row, want) {
+					t.Fatalf("Row %d = %v, want %v", i, row, want)
+				}
+			}
+		})
+	}
+}
+
+var nullableColumns = []Column{
+	{Name: "fileID", Kind: Int64},
+	{Name: "feed", Kind: Int64, Nullable: true},
+	{Name: "speaker", Kind: String, Nullable: true},
+}
+
+func nullableRow(i int) []any {
+	row := []any{int64(i), nil, nil}
+	if i%3 != 0 {
+		row[1] = int64(i % 5)
+	}
+	if i%7 != 0 {
+		row[2] = fmt.Sprintf("speaker %d", i)
+	}
+	return row
+}
+
+func TestParquetNulls(t *testing.T) {
+	for _, count := range []int{1, 9, 20, rowGroupSize + 3} {
+		t.Run(fmt.Sprintf("%d rows", count), func(t *testing.T) {
+			out := bytes.Buffer{}
+			w, err := NewWriter("parquet", &out, nullableColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range count {
+				if err := w.Write(nullableRow(i)...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			_, rows := readParquet(t, out.Bytes())
+			if len(rows) != count {
+				t.Fatalf("Read %d rows, wrote %d", len(rows), count)
+			}
+			for i, row := range rows {
+				if want := nullableRow(i); !reflect.DeepEqual(row, want) {
+					t.Fatalf("Row %d = %v, want %v", i, row, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTextFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "date,fileID,speaker\n1965-01-01,-5,speaker “0”\n1965-01-02,-4,speaker “1”\n"},
+		{"jsonl", "{\"date\":\"1965-01-01\",\"fileID\":-5,\"speaker\":\"speaker “0”\"}\n{\"date\":\"1965-01-02\",\"fileID\":-4,\"speaker\":\"speaker “1”\"}\n"},
+	}
+	for _, test := range tests {
+		out := bytes.Buffer{}
+		w, err := NewWriter(test.format, &out, testColumns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range 2 {
+			if err := w.Write(testRow(i)...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%s export:\n%s\nwant:\n%s", test.format, out.String(), test.want)
+		}
+	}
+}
+
+func TestTextNulls(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "fileID,feed,speaker\n0,,\n1,1,speaker 1\n"},
+		{"jsonl", "{\"fileID\":0,\"feed\":null,\"speaker\":null}\n{\"fileID\":1,\"feed\":1,\"speaker\":\"speaker 1\"}\n"},
+	}
+	for _, test := range tests {
+		out := bytes.Buffer{}
+		w, err := NewWriter(test.format, &out, nullableColumns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range 2 {
+			if err := w.Write(nullableRow(i)...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%s export:\n%s\nwant:\n%s", test.format, out.String(), test.want)
+		}
+	}
+}
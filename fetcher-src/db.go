@@ -2,20 +2,104 @@ package fetcher
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 
 	"language-analysis/config"
+	migrations "language-analysis/migrations-src"
 )
 
+var Schema = migrations.Database{
+	Name:     "fetcher",
+	Filename: "fetcher.db",
+	Migrations: []migrations.Migration{
+		migrations.Migration{
+			Name:  "Create feeds and files",
+			Probe: "SELECT feedID FROM feeds LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE feeds (
+					feedID INTEGER PRIMARY KEY AUTOINCREMENT,
+					urlTemplate TEXT UNIQUE,
+					scraperRx TEXT,
+					scraperRxGroup INTEGER,
+					earliestDateLimit DATE,
+
+					earliestFetchDate DATE,
+					earliestFetchDateTimestamp TIMESTAMP,
+					latestFetchDate DATE,
+					latestFetchDateTimestamp TIMESTAMP)`,
+				`CREATE TABLE files (
+					fileID INTEGER PRIMARY KEY AUTOINCREMENT,
+					feedID INTEGER REFERENCES feed (feedID),
+					url TEXT UNIQUE,
+					date DATE,
+					fetchTimestamp TIMESTAMP,
+					purgeTimestamp TIMESTAMP)`,
+				`CREATE INDEX filesFetchTimestamp ON files (fetchTimestamp)`,
+				`CREATE INDEX filesPurgeTimestamp ON files (purgeTimestamp)`,
+				`CREATE INDEX filesFeedIDFetchTimestamp ON files (feedID, fetchTimestamp)`,
+				`CREATE INDEX filesFeedIDPurgeTimestamp ON files (feedID, purgeTimestamp)`,
+				`CREATE INDEX filesDate ON files (date)`,
+				`CREATE INDEX filesDatePurgeTimestamp ON files (date, purgeTimestamp)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add file claims",
+			Probe: "SELECT claimTimestamp FROM files LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE files ADD COLUMN claimTimestamp TIMESTAMP`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add conditional fetch headers",
+			Probe: "SELECT etag, lastModified FROM files LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE files ADD COLUMN etag TEXT`,
+				`ALTER TABLE files ADD COLUMN lastModified TEXT`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add feed sources",
+			Probe: "SELECT source, sourceOptions FROM feeds LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE feeds ADD COLUMN source TEXT`,
+				`ALTER TABLE feeds ADD COLUMN sourceOptions TEXT`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add feed extraction rules",
+			Probe: "SELECT extractStart, extractItem, extractEnd FROM feeds LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE feeds ADD COLUMN extractStart TEXT`,
+				`ALTER TABLE feeds ADD COLUMN extractItem TEXT`,
+				`ALTER TABLE feeds ADD COLUMN extractEnd TEXT`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add fetch attempts",
+			Probe: "SELECT attempts FROM files LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE files ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0`,
+				`ALTER TABLE files ADD COLUMN lastError TEXT`,
+				`ALTER TABLE files ADD COLUMN lastAttemptTimestamp TIMESTAMP`,
+				`ALTER TABLE files ADD COLUMN nextAttemptTimestamp TIMESTAMP`,
+				`ALTER TABLE files ADD COLUMN deadTimestamp TIMESTAMP`,
+				`CREATE INDEX filesAttempts ON files (attempts)`,
+			},
+		},
+	},
+}
+
 type fetcherDB struct {
 	db *sql.DB
 }
 
 func openFetcherDB() (*fetcherDB, error) {
-	db, err := sql.Open("sqlite3", config.Dir()+"/fetcher.db")
+	db, err := sql.Open("sqlite3", config.Dir()+"/"+Schema.Filename+"?_busy_timeout=10000&_txlock=immediate")
 	if err != nil {
 		return nil, err
 	}
@@ -33,51 +117,7 @@ func (db *fetcherDB) Close() error {
 }
 
 func (db *fetcherDB) init() error {
-	if _, err := db.db.Query("SELECT feedID FROM feeds LIMIT 1"); err == nil {
-		return nil
-	}
-
-	tx, err := db.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	for _, statement := range []string{
-		`CREATE TABLE feeds (
-			feedID INTEGER PRIMARY KEY AUTOINCREMENT,
-			urlTemplate TEXT UNIQUE,
-			scraperRx TEXT,
-			scraperRxGroup INTEGER,
-			earliestDateLimit DATE,
-
-			earliestFetchDate DATE,
-			earliestFetchDateTimestamp TIMESTAMP,
-			latestFetchDate DATE,
-			latestFetchDateTimestamp TIMESTAMP)`,
-		`CREATE TABLE files (
-			fileID INTEGER PRIMARY KEY AUTOINCREMENT,
-			feedID INTEGER REFERENCES feed (feedID),
-			url TEXT UNIQUE,
-			date DATE,
-			fetchTimestamp TIMESTAMP,
-			purgeTimestamp TIMESTAMP)`,
-		`CREATE INDEX filesFetchTimestamp ON files (fetchTimestamp)`,
-		`CREATE INDEX filesPurgeTimestamp ON files (purgeTimestamp)`,
-		`CREATE INDEX filesFeedIDFetchTimestamp ON files (feedID, fetchTimestamp)`,
-		`CREATE INDEX filesFeedIDPurgeTimestamp ON files (feedID, purgeTimestamp)`,
-		`CREATE INDEX filesDate ON files (date)`,
-		`CREATE INDEX filesDatePurgeTimestamp ON files (date, purgeTimestamp)`,
-	} {
-		if _, err := tx.Exec(statement); err != nil {
-			return err
-		}
-	}
-
-	if err := tx.Commit(); err != nil {
-		return err
-	}
-	return nil
+	return Schema.Apply(db.db)
 }
 
 func parseDate(dateString sql.NullString) time.Time {
@@ -90,8 +130,46 @@ func parseTimestamp(timestampString sql.NullString) time.Time {
 	return t
 }
 
+const feedColumns = "feedID, urlTemplate, scraperRx, scraperRxGroup, earliestDateLimit, earliestFetchDate, earliestFetchDateTimestamp, latestFetchDate, latestFetchDateTimestamp, source, sourceOptions, extractStart, extractItem, extractEnd"
+
+func scanFeed(rows *sql.Rows) (Feed, error) {
+	feed := Feed{}
+	var earliestDateLimit sql.NullString
+	var earliestFetchDate, earliestFetchDateTimestamp sql.NullString
+	var latestFetchDate, latestFetchDateTimestamp sql.NullString
+	var source, sourceOptions sql.NullString
+	var extractStart, extractItem, extractEnd sql.NullString
+	if err := rows.Scan(&feed.feedID, &feed.urlTemplate, &feed.scraperRx, &feed.scraperRxGroup, &earliestDateLimit, &earliestFetchDate, &earliestFetchDateTimestamp, &latestFetchDate, &latestFetchDateTimestamp, &source, &sourceOptions, &extractStart, &extractItem, &extractEnd); err != nil {
+		return Feed{}, err
+	}
+	feed.earliestDateLimit = parseDate(earliestDateLimit)
+	feed.earliestFetchDate = parseDate(earliestFetchDate)
+	feed.earliestFetchDateTimestamp = parseTimestamp(earliestFetchDateTimestamp)
+	feed.latestFetchDate = parseDate(latestFetchDate)
+	feed.latestFetchDateTimestamp = parseTimestamp(latestFetchDateTimestamp)
+	feed.source = source.String
+	feed.extractStart = extractStart.String
+	feed.extractItem = extractItem.String
+	feed.extractEnd = extractEnd.String
+	feed.sourceOptions = map[string]string{}
+	if sourceOptions.String != "" {
+		if err := json.Unmarshal([]byte(sourceOptions.String), &feed.sourceOptions); err != nil {
+			return Feed{}, fmt.Errorf("Invalid sourceOptions for feed %d: %v", feed.feedID, err)
+		}
+	}
+	return feed, nil
+}
+
+func encodeSourceOptions(sourceOptions map[string]string) (string, error) {
+	if len(sourceOptions) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(sourceOptions)
+	return string(data), err
+}
+
 func (db *fetcherDB) feeds() ([]Feed, error) {
-	rows, err := db.db.Query("SELECT feedID, urlTemplate, scraperRx, scraperRxGroup, earliestDateLimit, earliestFetchDate, earliestFetchDateTimestamp, latestFetchDate, latestFetchDateTimestamp FROM feeds")
+	rows, err := db.db.Query("SELECT " + feedColumns + " FROM feeds")
 	if err != nil {
 		return nil, err
 	}
@@ -99,48 +177,44 @@ func (db *fetcherDB) feeds() ([]Feed, error) {
 
 	feeds := []Feed{}
 	for rows.Next() {
-		feed := Feed{}
-		var earliestDateLimit sql.NullString
-		var earliestFetchDate, earliestFetchDateTimestamp sql.NullString
-		var latestFetchDate, latestFetchDateTimestamp sql.NullString
-		if err := rows.Scan(&feed.feedID, &feed.urlTemplate, &feed.scraperRx, &feed.scraperRxGroup, &earliestDateLimit, &earliestFetchDate, &earliestFetchDateTimestamp, &latestFetchDate, &latestFetchDateTimestamp); err != nil {
+		feed, err := scanFeed(rows)
+		if err != nil {
 			return nil, err
 		}
-		feed.earliestDateLimit = parseDate(earliestDateLimit)
-		feed.earliestFetchDate = parseDate(earliestFetchDate)
-		feed.earliestFetchDateTimestamp = parseTimestamp(earliestFetchDateTimestamp)
-		feed.latestFetchDate = parseDate(latestFetchDate)
-		feed.latestFetchDateTimestamp = parseTimestamp(latestFetchDateTimestamp)
 		feeds = append(feeds, feed)
 	}
 	return feeds, nil
 }
 
 func (db *fetcherDB) feed(feedID int64) (Feed, error) {
-	rows, err := db.db.Query("SELECT feedID, urlTemplate, scraperRx, scraperRxGroup, earliestDateLimit, earliestFetchDate, earliestFetchDateTimestamp, latestFetchDate, latestFetchDateTimestamp FROM feeds")
+	rows, err := db.db.Query("SELECT "+feedColumns+" FROM feeds WHERE feedID = ?", feedID)
 	if err != nil {
 		return Feed{}, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		feed := Feed{}
-		var earliestDateLimit sql.NullString
-		var earliestFetchDate, earliestFetchDateTimestamp sql.NullString
-		var latestFetchDate, latestFetchDateTimestamp sql.NullString
-		if err := rows.Scan(&feed.feedID, &feed.urlTemplate, &feed.scraperRx, &feed.scraperRxGroup, &earliestDateLimit, &earliestFetchDate, &earliestFetchDateTimestamp, &latestFetchDate, &latestFetchDateTimestamp); err != nil {
-			return Feed{}, err
-		}
-		feed.earliestDateLimit = parseDate(earliestDateLimit)
-		feed.earliestFetchDate = parseDate(earliestFetchDate)
-		feed.earliestFetchDateTimestamp = parseTimestamp(earliestFetchDateTimestamp)
-		feed.latestFetchDate = parseDate(latestFetchDate)
-		feed.latestFetchDateTimestamp = parseTimestamp(latestFetchDateTimestamp)
-		return feed, nil
+		return scanFeed(rows)
 	}
 	return Feed{}, fmt.Errorf("Nonexistent feedID: %d", feedID)
 }
 
+const FileColumns = "fileID, feedID, url, date, fetchTimestamp, purgeTimestamp"
+
+func ScanFile(rows *sql.Rows) (File, error) {
+	file := File{}
+	var date sql.NullString
+	var fetchTimestamp sql.NullString
+	var purgeTimestamp sql.NullString
+	if err := rows.Scan(&file.fileID, &file.feedID, &file.url, &date, &fetchTimestamp, &purgeTimestamp); err != nil {
+		return File{}, err
+	}
+	file.date = parseDate(date)
+	file.fetchTimestamp = parseTimestamp(fetchTimestamp)
+	file.purgeTimestamp = parseTimestamp(purgeTimestamp)
+	return file, nil
+}
+
 func (db *fetcherDB) file(fileID int64) (File, error) {
 	rows, err := db.db.Query("SELECT fileID, feedID, url, date, fetchTimestamp, purgeTimestamp FROM files WHERE fileID = ?", fileID)
 	if err != nil {
@@ -186,8 +260,65 @@ func (db *fetcherDB) unfetched(limit int) ([]File, error) {
 	return files, nil
 }
 
-func (db *fetcherDB) fetchedSince(since time.Time, limit int) ([]File, error) {
-	rows, err := db.db.Query("SELECT fileID, feedID, url, date, fetchTimestamp FROM files WHERE fetchTimestamp > ? AND purgeTimestamp IS NULL ORDER BY fetchTimestamp ASC LIMIT ?", since.Format(time.DateTime), limit)
+func (db *fetcherDB) claimUnfetched(staleClaimTimestamp time.Time) (File, bool, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return File{}, false, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT fileID, feedID, url, date, purgeTimestamp, etag, lastModified FROM files WHERE fetchTimestamp IS NULL AND deadTimestamp IS NULL AND (nextAttemptTimestamp IS NULL OR nextAttemptTimestamp <= DATETIME()) AND (claimTimestamp IS NULL OR claimTimestamp < ?) ORDER BY fileID ASC LIMIT 1", staleClaimTimestamp.Format(time.DateTime))
+	if err != nil {
+		return File{}, false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return File{}, false, rows.Err()
+	}
+	file := File{}
+	var date sql.NullString
+	var purgeTimestamp sql.NullString
+	var etag, lastModified sql.NullString
+	if err := rows.Scan(&file.fileID, &file.feedID, &file.url, &date, &purgeTimestamp, &etag, &lastModified); err != nil {
+		return File{}, false, err
+	}
+	file.date = parseDate(date)
+	file.purgeTimestamp = parseTimestamp(purgeTimestamp)
+	file.etag = etag.String
+	file.lastModified = lastModified.String
+	rows.Close()
+
+	if _, err := tx.Exec("UPDATE files SET claimTimestamp = DATETIME() WHERE fileID = ?", file.fileID); err != nil {
+		return File{}, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return File{}, false, err
+	}
+	return file, true, nil
+}
+
+func (db *fetcherDB) fetchedAfter(since time.Time, afterFileID int64, limit int) ([]File, error) {
+	rows, err := db.db.Query("SELECT "+FileColumns+" FROM files WHERE fetchTimestamp > ? OR (fetchTimestamp = ? AND fileID > ?) ORDER BY fetchTimestamp ASC, fileID ASC LIMIT ?", since.Format(time.DateTime), since.Format(time.DateTime), afterFileID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []File{}
+	for rows.Next() {
+		file, err := ScanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+func (db *fetcherDB) fetchedByDate(from, to time.Time, afterFileID int64, limit int) ([]File, error) {
+	rows, err := db.db.Query("SELECT fileID, feedID, url, date, fetchTimestamp, purgeTimestamp FROM files WHERE date >= ? AND date <= ? AND fileID > ? AND fetchTimestamp IS NOT NULL ORDER BY fileID ASC LIMIT ?", from.Format(time.DateOnly), to.Format(time.DateOnly), afterFileID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -198,24 +329,55 @@ func (db *fetcherDB) fetchedSince(since time.Time, limit int) ([]File, error) {
 		file := File{}
 		var date sql.NullString
 		var fetchTimestamp sql.NullString
-		if err := rows.Scan(&file.fileID, &file.feedID, &file.url, &date, &fetchTimestamp); err != nil {
+		var purgeTimestamp sql.NullString
+		if err := rows.Scan(&file.fileID, &file.feedID, &file.url, &date, &fetchTimestamp, &purgeTimestamp); err != nil {
 			return nil, err
 		}
 		file.date = parseDate(date)
 		file.fetchTimestamp = parseTimestamp(fetchTimestamp)
+		file.purgeTimestamp = parseTimestamp(purgeTimestamp)
 		files = append(files, file)
 	}
-	return files, nil
+	return files, rows.Err()
 }
 
-func (db *fetcherDB) addFeed(urlTemplate, scraperRx string, scraperRxGroup int, earliestDateLimit time.Time) error {
+func (db *fetcherDB) addFeed(urlTemplate, scraperRx string, scraperRxGroup int, earliestDateLimit time.Time, source string, sourceOptions map[string]string, extractStart, extractItem, extractEnd string) (int64, error) {
+	options, err := encodeSourceOptions(sourceOptions)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO feeds (urlTemplate, scraperRx, scraperRxGroup, earliestDateLimit, source, sourceOptions, extractStart, extractItem, extractEnd) VALUES (?,?,?,?,?,?,?,?,?)", urlTemplate, scraperRx, scraperRxGroup, earliestDateLimit.Format(time.DateOnly), source, options, extractStart, extractItem, extractEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	feedID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return feedID, tx.Commit()
+}
+
+func (db *fetcherDB) updateFeed(feedID int64, scraperRx string, scraperRxGroup int, earliestDateLimit time.Time, source string, sourceOptions map[string]string, extractStart, extractItem, extractEnd string) error {
+	options, err := encodeSourceOptions(sourceOptions)
+	if err != nil {
+		return err
+	}
+
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("INSERT INTO feeds (urlTemplate, scraperRx, scraperRxGroup, earliestDateLimit) VALUES (?,?,?,?)", urlTemplate, scraperRx, scraperRxGroup, earliestDateLimit.Format(time.DateOnly)); err != nil {
+	if _, err := tx.Exec("UPDATE feeds SET scraperRx = ?, scraperRxGroup = ?, earliestDateLimit = ?, source = ?, sourceOptions = ?, extractStart = ?, extractItem = ?, extractEnd = ? WHERE feedID = ?", scraperRx, scraperRxGroup, earliestDateLimit.Format(time.DateOnly), source, options, extractStart, extractItem, extractEnd, feedID); err != nil {
 		return err
 	}
 
@@ -236,48 +398,163 @@ func (db *fetcherDB) updateFeedEarliestFetched(feedID int64, earliestFetchedDate
 	return tx.Commit()
 }
 
-func (db *fetcherDB) updateFeedLatestFetched(feedID int64, latestFetchedDate time.Time) error {
+func (db *fetcherDB) touchFeed(feedID int64) error {
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("UPDATE feeds SET latestFetchDate = ?, latestFetchDateTimestamp = DATETIME() WHERE feedID = ?", latestFetchedDate.Format(time.DateOnly), feedID); err != nil {
+	if _, err := tx.Exec("UPDATE feeds SET earliestFetchDateTimestamp = DATETIME() WHERE feedID = ?", feedID); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func (db *fetcherDB) addFile(feedID int64, url string, date time.Time) error {
+func (db *fetcherDB) updateFeedLatestFetched(feedID int64, latestFetchedDate time.Time) error {
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("INSERT INTO files (feedID, url, date) VALUES (?,?,?)", feedID, url, date.Format(time.DateOnly)); err != nil {
+	if _, err := tx.Exec("UPDATE feeds SET latestFetchDate = ?, latestFetchDateTimestamp = DATETIME() WHERE feedID = ?", latestFetchedDate.Format(time.DateOnly), feedID); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func (db *fetcherDB) updateFileFetched(fileID int64) error {
+func (db *fetcherDB) addFeedFiles(feedID int64, links []Link, earliestFetchDate, latestFetchDate time.Time) (int, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	count := 0
+	for _, link := range links {
+		result, err := tx.Exec("INSERT OR IGNORE INTO files (feedID, url, date) VALUES (?,?,?)", feedID, link.URL, link.Date.Format(time.DateOnly))
+		if err != nil {
+			return 0, err
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			count += int(n)
+		}
+	}
+
+	if !earliestFetchDate.IsZero() {
+		if _, err := tx.Exec("UPDATE feeds SET earliestFetchDate = ?, earliestFetchDateTimestamp = DATETIME() WHERE feedID = ?", earliestFetchDate.Format(time.DateOnly), feedID); err != nil {
+			return 0, err
+		}
+	}
+	if !latestFetchDate.IsZero() {
+		if _, err := tx.Exec("UPDATE feeds SET latestFetchDate = ?, latestFetchDateTimestamp = DATETIME() WHERE feedID = ?", latestFetchDate.Format(time.DateOnly), feedID); err != nil {
+			return 0, err
+		}
+	}
+
+	return count, tx.Commit()
+}
+
+func (db *fetcherDB) updateFileFetched(fileID int64, etag, lastModified string) error {
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("UPDATE files SET fetchTimestamp = DATETIME(), purgeTimestamp = NULL WHERE fileID = ?", fileID); err != nil {
+	if _, err := tx.Exec("UPDATE files SET fetchTimestamp = DATETIME(), purgeTimestamp = NULL, claimTimestamp = NULL, etag = ?, lastModified = ?, attempts = 0, lastError = NULL, nextAttemptTimestamp = NULL, deadTimestamp = NULL WHERE fileID = ?", etag, lastModified, fileID); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
+func (db *fetcherDB) updateFileFailed(fileID int64, fetchErr error, maxAttempts int, retryDelay time.Duration) (bool, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	attempts := 0
+	if err := tx.QueryRow("SELECT attempts FROM files WHERE fileID = ?", fileID).Scan(&attempts); err != nil {
+		return false, err
+	}
+	attempts++
+
+	dead := attempts >= maxAttempts
+	nextAttemptTimestamp := time.Now().UTC().Add(retryDelay << min(attempts-1, 16))
+	var statusErr statusError
+	if errors.As(fetchErr, &statusErr) && time.Now().UTC().Add(statusErr.retryAfter).After(nextAttemptTimestamp) {
+		nextAttemptTimestamp = time.Now().UTC().Add(statusErr.retryAfter)
+	}
+	if dead {
+		if _, err := tx.Exec("UPDATE files SET attempts = ?, lastError = ?, lastAttemptTimestamp = DATETIME(), nextAttemptTimestamp = NULL, deadTimestamp = DATETIME(), claimTimestamp = NULL WHERE fileID = ?", attempts, fetchErr.Error(), fileID); err != nil {
+			return false, err
+		}
+	} else {
+		if _, err := tx.Exec("UPDATE files SET attempts = ?, lastError = ?, lastAttemptTimestamp = DATETIME(), nextAttemptTimestamp = ?, claimTimestamp = NULL WHERE fileID = ?", attempts, fetchErr.Error(), nextAttemptTimestamp.Format(time.DateTime), fileID); err != nil {
+			return false, err
+		}
+	}
+
+	return dead, tx.Commit()
+}
+
+func (db *fetcherDB) failures(limit int) ([]File, error) {
+	rows, err := db.db.Query("SELECT fileID, feedID, url, date, attempts, lastError, lastAttemptTimestamp, nextAttemptTimestamp, deadTimestamp FROM files WHERE fetchTimestamp IS NULL AND attempts > 0 ORDER BY fileID ASC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []File{}
+	for rows.Next() {
+		file := File{}
+		var date sql.NullString
+		var lastError sql.NullString
+		var lastAttemptTimestamp, nextAttemptTimestamp, deadTimestamp sql.NullString
+		if err := rows.Scan(&file.fileID, &file.feedID, &file.url, &date, &file.attempts, &lastError, &lastAttemptTimestamp, &nextAttemptTimestamp, &deadTimestamp); err != nil {
+			return nil, err
+		}
+		file.date = parseDate(date)
+		file.lastError = lastError.String
+		file.lastAttemptTimestamp = parseTimestamp(lastAttemptTimestamp)
+		file.nextAttemptTimestamp = parseTimestamp(nextAttemptTimestamp)
+		file.deadTimestamp = parseTimestamp(deadTimestamp)
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (db *fetcherDB) retryFailed(fileID int64) (int64, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE files SET attempts = 0, nextAttemptTimestamp = NULL, deadTimestamp = NULL, claimTimestamp = NULL WHERE fetchTimestamp IS NULL AND attempts > 0"
+	args := []any{}
+	if fileID != 0 {
+		query += " AND fileID = ?"
+		args = append(args, fileID)
+	}
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
 func (db *fetcherDB) earliestFetched(beforeTimestamp time.Time, limit int) ([]File, error) {
 	rows, err := db.db.Query("SELECT fileID, feedID, url, date, fetchTimestamp FROM files WHERE fetchTimestamp < ? AND purgeTimestamp IS NULL ORDER BY fetchTimestamp ASC LIMIT ?", beforeTimestamp.Format(time.DateTime), limit)
 	if err != nil {
@@ -354,7 +631,7 @@ func (db *fetcherDB) reenqueue(fileID int64) (bool, error) {
 	}
 	defer tx.Rollback()
 
-	result, err := tx.Exec("UPDATE files SET fetchTimestamp = NULL WHERE fileID = ?", fileID)
+	result, err := tx.Exec("UPDATE files SET fetchTimestamp = NULL, attempts = 0, nextAttemptTimestamp = NULL, deadTimestamp = NULL, claimTimestamp = NULL WHERE fileID = ?", fileID)
 	if err != nil {
 		return false, err
 	}
@@ -377,7 +654,7 @@ func (db *fetcherDB) reenqueueDateRange(start, end time.Time) (int64, error) {
 	}
 	defer tx.Rollback()
 
-	result, err := tx.Exec("UPDATE files SET fetchTimestamp = NULL WHERE date >= ? AND date <= ? AND purgeTimestamp IS NOT NULL", start.Format(time.DateOnly), end.Format(time.DateOnly))
+	result, err := tx.Exec("UPDATE files SET fetchTimestamp = NULL, attempts = 0, nextAttemptTimestamp = NULL, deadTimestamp = NULL, claimTimestamp = NULL WHERE date >= ? AND date <= ? AND purgeTimestamp IS NOT NULL", start.Format(time.DateOnly), end.Format(time.DateOnly))
 	if err != nil {
 		return 0, err
 	}
@@ -404,3 +681,11 @@ func (db *fetcherDB) countUnfetched(feedID int64) (int, error) {
 	}
 	return 0, nil
 }
+
+func (db *fetcherDB) countDead(feedID int64) (int, error) {
+	count := 0
+	if err := db.db.QueryRow("SELECT COUNT(*) FROM files WHERE feedID = ? AND fetchTimestamp IS NULL AND deadTimestamp IS NOT NULL", feedID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
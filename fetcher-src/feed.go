@@ -2,8 +2,9 @@ package fetcher
 
 import (
 	"fmt"
-	"regexp"
 	"time"
+
+	"language-analysis/config"
 )
 
 type Feed struct {
@@ -12,6 +13,11 @@ type Feed struct {
 	scraperRx         string
 	scraperRxGroup    int
 	earliestDateLimit time.Time
+	source            string
+	sourceOptions     map[string]string
+	extractStart      string
+	extractItem       string
+	extractEnd        string
 
 	earliestFetchDate          time.Time
 	earliestFetchDateTimestamp time.Time
@@ -19,21 +25,43 @@ type Feed struct {
 	latestFetchDateTimestamp   time.Time
 }
 
-func AddFeed(urlTemplate, scraperRx string, scraperRxGroup int, earliestDateLimit time.Time) error {
+func AddFeed(urlTemplate, scraperRx string, scraperRxGroup int, earliestDateLimit time.Time, source string, sourceOptions map[string]string) error {
 	db, err := openFetcherDB()
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	return db.addFeed(urlTemplate, scraperRx, scraperRxGroup, earliestDateLimit)
+	_, err = db.addFeed(urlTemplate, scraperRx, scraperRxGroup, earliestDateLimit, source, sourceOptions, "", "", "")
+	return err
+}
+
+func FeedByID(feedID int64) (Feed, error) {
+	db, err := openFetcherDB()
+	if err != nil {
+		return Feed{}, err
+	}
+	defer db.Close()
+
+	return db.feed(feedID)
+}
+
+func (feed Feed) ID() int64 {
+	return feed.feedID
+}
+
+func (feed Feed) ExtractRules() (string, string, string) {
+	return feed.extractStart, feed.extractItem, feed.extractEnd
 }
 
 func fetchFeed(feed Feed, db *fetcherDB) (bool, error) {
-	feedRegex, err := regexp.Compile(feed.scraperRx)
+	source, err := sourceFor(feed)
 	if err != nil {
 		return false, err
 	}
+	if !source.Dated() {
+		return fetchIndexFeed(feed, source, db)
+	}
 
 	earliest := true
 	fetchDate := feed.earliestFetchDate
@@ -64,34 +92,50 @@ func fetchFeed(feed Feed, db *fetcherDB) (bool, error) {
 		}
 	}
 
-	feedData, err := fetch(fmt.Sprintf(feed.urlTemplate, fetchDate.Format(time.DateOnly)))
+	links, err := source.Links(feed, fetchDate)
 	if err != nil {
 		return false, err
 	}
 
-	count := 0
-	for _, match := range feedRegex.FindAllSubmatchIndex(feedData, -1) {
-		if len(match) > 2*feed.scraperRxGroup+1 {
-			link := string(feedData[match[2*feed.scraperRxGroup]:match[2*feed.scraperRxGroup+1]])
-			if err := db.addFile(feed.feedID, link, fetchDate); err != nil {
-				fmt.Printf("Error: addFile: %v\n", err)
-			} else {
-				count++
-			}
-		}
+	var count int
+	if earliest {
+		count, err = db.addFeedFiles(feed.feedID, links, fetchDate, time.Time{})
+	} else {
+		count, err = db.addFeedFiles(feed.feedID, links, time.Time{}, fetchDate)
+	}
+	if err != nil {
+		return false, err
 	}
 	fmt.Printf("enqueued %d file(s).\n", count)
+	return true, nil
+}
 
-	if earliest {
-		err := db.updateFeedEarliestFetched(feed.feedID, fetchDate)
-		if err != nil {
-			return false, err
-		}
-	} else {
-		db.updateFeedLatestFetched(feed.feedID, fetchDate)
-		if err != nil {
-			return false, err
+func fetchIndexFeed(feed Feed, source Source, db *fetcherDB) (bool, error) {
+	interval := config.Duration("fetcher-index-interval")
+	if time.Since(feed.latestFetchDateTimestamp) < interval {
+		return false, db.touchFeed(feed.feedID)
+	}
+
+	today := time.Now().UTC()
+	links, err := source.Links(feed, today)
+	if err != nil {
+		return false, err
+	}
+
+	earliestDate := feed.earliestFetchDate
+	for _, link := range links {
+		if earliestDate.IsZero() || link.Date.Before(earliestDate) {
+			earliestDate = link.Date
 		}
 	}
+	if earliestDate.IsZero() {
+		earliestDate = today
+	}
+
+	count, err := db.addFeedFiles(feed.feedID, links, earliestDate, today)
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("enqueued %d file(s).\n", count)
 	return true, nil
 }
@@ -1,18 +1,170 @@
 package fetcher
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
+
+	"language-analysis/config"
 )
 
+type fetchResult struct {
+	data         []byte
+	notModified  bool
+	etag         string
+	lastModified string
+}
+
+type statusError struct {
+	url        string
+	statusCode int
+	status     string
+	retryAfter time.Duration
+}
+
+func (err statusError) Error() string {
+	return fmt.Sprintf("%s: %s", err.url, err.status)
+}
+
+func (err statusError) retryable() bool {
+	return err.statusCode == http.StatusTooManyRequests || err.statusCode >= 500
+}
+
+func isNotFound(err error) bool {
+	var statusErr statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode == http.StatusNotFound || statusErr.statusCode == http.StatusGone
+	}
+	return false
+}
+
+var fetchSettings = struct {
+	client     *http.Client
+	userAgent  string
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}{
+	client:     &http.Client{Timeout: 60 * time.Second},
+	userAgent:  "language-analysis",
+	retries:    3,
+	backoff:    2 * time.Second,
+	maxBackoff: 5 * time.Minute,
+}
+
+func configureFetch() error {
+	if err := configureHosts(); err != nil {
+		return err
+	}
+	timeout := config.Duration("fetcher-timeout")
+	retries := config.Int("fetcher-retries")
+	backoff := config.Duration("fetcher-backoff")
+	maxBackoff := config.Duration("fetcher-max-backoff")
+	fetchSettings.client = &http.Client{Timeout: timeout}
+	fetchSettings.userAgent = config.String("fetcher-user-agent")
+	fetchSettings.retries = retries
+	fetchSettings.backoff = backoff
+	fetchSettings.maxBackoff = maxBackoff
+	return nil
+}
+
 func fetch(url string) ([]byte, error) {
-	fmt.Printf("Fetching %s...", url)
-	defer fmt.Printf("done\n")
-	response, err := http.Get(url)
+	result, err := fetchConditional(url, "", "")
+	return result.data, err
+}
+
+func fetchConditional(url, etag, lastModified string) (fetchResult, error) {
+	backoff := fetchSettings.backoff
+	for attempt := 0; ; attempt++ {
+		result, err := fetchOnce(url, etag, lastModified)
+		if err == nil {
+			return result, nil
+		}
+
+		var statusErr statusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			return fetchResult{}, err
+		}
+		if attempt >= fetchSettings.retries {
+			return fetchResult{}, err
+		}
+
+		delay := backoff
+		if statusErr.retryAfter > delay {
+			delay = statusErr.retryAfter
+		}
+		if delay > fetchSettings.maxBackoff {
+			return fetchResult{}, fmt.Errorf("%w (retry after %s)", err, delay)
+		}
+		fmt.Printf("Retrying %s in %s: %v\n", url, delay, err)
+		time.Sleep(delay)
+		backoff *= 2
+	}
+}
+
+func fetchOnce(url, etag, lastModified string) (fetchResult, error) {
+	hosts.wait(url)
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	request.Header.Set("User-Agent", fetchSettings.userAgent)
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		request.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	response, err := fetchSettings.client.Do(request)
 	if err != nil {
-		return nil, err
+		return fetchResult{}, err
 	}
 	defer response.Body.Close()
-	return io.ReadAll(response.Body)
+
+	if response.StatusCode == http.StatusNotModified && (etag != "" || lastModified != "") {
+		fmt.Printf("Not modified %s\n", url)
+		return fetchResult{
+			notModified:  true,
+			etag:         etag,
+			lastModified: lastModified,
+		}, nil
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		io.Copy(io.Discard, response.Body)
+		return fetchResult{}, statusError{
+			url:        url,
+			statusCode: response.StatusCode,
+			status:     response.Status,
+			retryAfter: parseRetryAfter(response.Header.Get("Retry-After")),
+		}
+	}
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	fmt.Printf("Fetched %s\n", url)
+	return fetchResult{
+		data:         data,
+		etag:         response.Header.Get("ETag"),
+		lastModified: response.Header.Get("Last-Modified"),
+	}, nil
+}
+
+func parseRetryAfter(retryAfter string) time.Duration {
+	if retryAfter == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(retryAfter); err == nil {
+		return time.Until(t)
+	}
+	return 0
 }
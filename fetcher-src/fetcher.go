@@ -1,7 +1,11 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"language-analysis/config"
@@ -14,6 +18,13 @@ var Config struct {
 		ScraperRx         string
 		ScraperRxGroup    int
 		EarliestDateLimit time.Time
+		Source            string
+		Options           map[string]string
+		Extract           struct {
+			Start string
+			Item  string
+			End   string
+		}
 	}
 }
 
@@ -31,6 +42,19 @@ func formatTimestamp(t time.Time) string {
 	return t.Format(time.DateTime)
 }
 
+func formatOptions(options map[string]string) string {
+	keys := []string{}
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := []string{}
+	for _, key := range keys {
+		items = append(items, fmt.Sprintf("%s=%q", key, options[key]))
+	}
+	return strings.Join(items, " ")
+}
+
 func StatusCommand() error {
 	db, err := openFetcherDB()
 	if err != nil {
@@ -74,6 +98,9 @@ func StatusCommand() error {
 		if unconfigured {
 			fmt.Printf("Feed %d (unconfigured): %s\n", feed.feedID, feed.urlTemplate)
 		}
+		if feed.source != "" {
+			fmt.Printf("    source: %s\n", feed.source)
+		}
 		fmt.Printf("    earliest: %s (%s)\n", formatDate(feed.earliestFetchDate), formatTimestamp(feed.earliestFetchDateTimestamp))
 		fmt.Printf("    latest: %s (%s)\n", formatDate(feed.latestFetchDate), formatTimestamp(feed.latestFetchDateTimestamp))
 		if count, err := db.countUnfetched(feed.feedID); err != nil {
@@ -81,81 +108,265 @@ func StatusCommand() error {
 		} else if count > 0 {
 			fmt.Printf("    pending unfetched count: %d\n", count)
 		}
+		if count, err := db.countDead(feed.feedID); err != nil {
+			fmt.Printf("    error fetching failed count: %v\n", err)
+		} else if count > 0 {
+			fmt.Printf("    failed count: %d\n", count)
+		}
 	}
 	return nil
 }
 
 func FetchCommand() error {
+	if err := configureFetch(); err != nil {
+		return err
+	}
+
 	db, err := openFetcherDB()
 	if err != nil {
 		return err
 	}
 	defer db.Close()
-	return fetchNext(db)
+	_, err = fetchNext(db)
+	return err
 }
 
 func AddFeedsCommand() error {
-	return fmt.Errorf("Not implemented.")
+	db, err := openFetcherDB()
+	if err != nil {
+		return fmt.Errorf("Failed to open fetcher database: %v", err)
+	}
+	defer db.Close()
+	feeds, err := db.feeds()
+	if err != nil {
+		return fmt.Errorf("Failed to get feeds from fetcher database: %v", err)
+	}
+
+	added, updated := 0, 0
+	for _, f := range Config.Feed {
+		var existing *Feed
+		for i := range feeds {
+			if f.URLTemplate == feeds[i].urlTemplate {
+				existing = &feeds[i]
+				break
+			}
+		}
+		if existing == nil {
+			_, err := db.addFeed(f.URLTemplate, f.ScraperRx, f.ScraperRxGroup, f.EarliestDateLimit, f.Source, f.Options, f.Extract.Start, f.Extract.Item, f.Extract.End)
+			if err != nil {
+				return fmt.Errorf("Failed to add feed %s: %v", f.Name, err)
+			}
+			fmt.Printf("+ %s: %s\n", f.Name, f.URLTemplate)
+			fmt.Printf("    + scraperRx: %q\n", f.ScraperRx)
+			fmt.Printf("    + scraperRxGroup: %d\n", f.ScraperRxGroup)
+			fmt.Printf("    + earliestDateLimit: %s\n", formatDate(f.EarliestDateLimit))
+			if f.Source != "" {
+				fmt.Printf("    + source: %s\n", f.Source)
+			}
+			if len(f.Options) > 0 {
+				fmt.Printf("    + options: %s\n", formatOptions(f.Options))
+			}
+			if f.Extract.Start != "" || f.Extract.Item != "" || f.Extract.End != "" {
+				fmt.Printf("    + extract: start=%q item=%q end=%q\n", f.Extract.Start, f.Extract.Item, f.Extract.End)
+			}
+			added++
+			continue
+		}
+
+		changed := false
+		diff := func(field, oldValue, newValue string) {
+			if oldValue == newValue {
+				return
+			}
+			if !changed {
+				fmt.Printf("~ Feed %d: %s\n", existing.feedID, f.Name)
+				changed = true
+			}
+			fmt.Printf("    - %s: %s\n", field, oldValue)
+			fmt.Printf("    + %s: %s\n", field, newValue)
+		}
+		diff("scraperRx", fmt.Sprintf("%q", existing.scraperRx), fmt.Sprintf("%q", f.ScraperRx))
+		diff("scraperRxGroup", fmt.Sprintf("%d", existing.scraperRxGroup), fmt.Sprintf("%d", f.ScraperRxGroup))
+		diff("earliestDateLimit", formatDate(existing.earliestDateLimit), formatDate(f.EarliestDateLimit))
+		diff("source", existing.source, f.Source)
+		diff("options", formatOptions(existing.sourceOptions), formatOptions(f.Options))
+		diff("extract.start", fmt.Sprintf("%q", existing.extractStart), fmt.Sprintf("%q", f.Extract.Start))
+		diff("extract.item", fmt.Sprintf("%q", existing.extractItem), fmt.Sprintf("%q", f.Extract.Item))
+		diff("extract.end", fmt.Sprintf("%q", existing.extractEnd), fmt.Sprintf("%q", f.Extract.End))
+		if changed {
+			if err := db.updateFeed(existing.feedID, f.ScraperRx, f.ScraperRxGroup, f.EarliestDateLimit, f.Source, f.Options, f.Extract.Start, f.Extract.Item, f.Extract.End); err != nil {
+				return fmt.Errorf("Failed to update feed %s: %v", f.Name, err)
+			}
+			updated++
+		}
+	}
+	fmt.Printf("Added %d feed(s), updated %d feed(s).\n", added, updated)
+	return nil
 }
 
-func FetchLoopCommand() error {
-	sleep, err := config.Duration("fetcher-sleep", 15*time.Second)
+func FailuresCommand() error {
+	limit := config.Int("failures-limit")
+
+	db, err := openFetcherDB()
+	if err != nil {
+		return fmt.Errorf("Failed to open fetcher database: %v", err)
+	}
+	defer db.Close()
+
+	files, err := db.failures(limit)
 	if err != nil {
 		return err
 	}
+	for _, file := range files {
+		if file.deadTimestamp.IsZero() {
+			fmt.Printf("File %d: %s %s\n", file.fileID, formatDate(file.date), file.url)
+			fmt.Printf("    attempts: %d, last: %s, next: %s\n", file.attempts, formatTimestamp(file.lastAttemptTimestamp), formatTimestamp(file.nextAttemptTimestamp))
+		} else {
+			fmt.Printf("File %d (failed): %s %s\n", file.fileID, formatDate(file.date), file.url)
+			fmt.Printf("    attempts: %d, last: %s\n", file.attempts, formatTimestamp(file.lastAttemptTimestamp))
+		}
+		fmt.Printf("    error: %s\n", file.lastError)
+	}
+	fmt.Printf("%d failing file(s).\n", len(files))
+	return nil
+}
+
+func RetryCommand() error {
+	var fileID int64
+	if len(config.Args()) != 1 {
+		return config.Usagef("Specify a fileID or all")
+	} else if file := config.Args()[0]; file != "all" {
+		if _, err := fmt.Sscanf(file, "%d", &fileID); err != nil || fileID == 0 {
+			return fmt.Errorf("Invalid fileID: %s", file)
+		}
+	}
 
 	db, err := openFetcherDB()
 	if err != nil {
-		return err
+		return fmt.Errorf("Failed to open fetcher database: %v", err)
 	}
 	defer db.Close()
 
-	for {
-		if err := fetchNext(db); err != nil {
-			return err
-		}
-		time.Sleep(sleep)
+	count, err := db.retryFailed(fileID)
+	if err != nil {
+		return err
 	}
+	fmt.Printf("Requeued %d file(s).\n", count)
+	return nil
 }
 
-func fetchNext(db *fetcherDB) error {
-	files, err := db.unfetched(10)
+func FetchLoopCommand() error {
+	sleep := config.Duration("fetcher-sleep")
+	workers := config.Int("fetcher-workers")
+	if err := configureFetch(); err != nil {
+		return err
+	}
+
+	db, err := openFetcherDB()
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	if len(files) > 0 {
-		var err error
-		for _, file := range files {
-			if err = fetchFile(file, db); err == nil {
-				return nil
+	// The first error stops the other workers, which finish the fetch
+	// they are in before the database is closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, max(workers, 1))
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				fetched, err := fetchNext(db)
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+				if !fetched {
+					select {
+					case <-ctx.Done():
+					case <-time.After(sleep):
+					}
+				}
 			}
+		}()
+	}
+	wg.Wait()
+	return <-errs
+}
+
+func configureHosts() error {
+	sleep := config.Duration("fetcher-sleep")
+	interval := config.Duration("fetcher-host-interval")
+	if interval == 0 {
+		interval = sleep
+	}
+	burst := config.Int("fetcher-host-burst")
+	hosts.configure(interval, burst)
+	return nil
+}
+
+var feedMutex sync.Mutex
+
+func fetchNext(db *fetcherDB) (bool, error) {
+	claimTimeout := config.Duration("fetcher-claim-timeout")
+	maxAttempts := config.Int("fetcher-max-attempts")
+	retryDelay := config.Duration("fetcher-retry-delay")
+
+	failed := false
+	for range 10 {
+		file, ok, err := db.claimUnfetched(time.Now().UTC().Add(-claimTimeout))
+		if err != nil {
+			return false, err
+		} else if !ok {
+			break
+		}
+		fetchErr := fetchFile(file, db)
+		if fetchErr == nil {
+			return true, nil
+		}
+		failed = true
+		if dead, err := db.updateFileFailed(file.fileID, fetchErr, maxAttempts, retryDelay); err != nil {
+			return false, err
+		} else if dead {
+			fmt.Printf("Error: file %d failed, giving up: %v\n", file.fileID, fetchErr)
+		} else {
+			fmt.Printf("Error: file %d failed: %v\n", file.fileID, fetchErr)
 		}
-		return err
 	}
+	if failed {
+		return false, nil
+	}
+
+	if !feedMutex.TryLock() {
+		return false, nil
+	}
+	defer feedMutex.Unlock()
 
 	feeds, err := db.feeds()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if len(feeds) == 0 {
-		return nil
+		return false, nil
 	}
 
 	feed := feeds[0]
 	for _, f := range feeds[1:] {
 		if feed.earliestFetchDateTimestamp.IsZero() {
 			if fetched, err := fetchFeed(feed, db); err != nil {
-				return err
+				return false, err
 			} else if fetched {
-				return nil
+				return true, nil
 			}
 			feed = f
 		} else if f.earliestFetchDateTimestamp.Before(feed.earliestFetchDateTimestamp) {
 			feed = f
 		}
 	}
-	_, err = fetchFeed(feed, db)
-	return err
+	return fetchFeed(feed, db)
 }
@@ -20,6 +20,15 @@ type File struct {
 
 	fetchTimestamp time.Time
 	purgeTimestamp time.Time
+
+	etag         string
+	lastModified string
+
+	attempts             int
+	lastError            string
+	lastAttemptTimestamp time.Time
+	nextAttemptTimestamp time.Time
+	deadTimestamp        time.Time
 }
 
 func (file File) Filename() string {
@@ -43,6 +52,10 @@ func (file File) ID() int64 {
 	return file.fileID
 }
 
+func (file File) FeedID() int64 {
+	return file.feedID
+}
+
 func (file File) Contents() ([]byte, error) {
 	fd, err := os.Open(file.Filename())
 	if err != nil {
@@ -59,14 +72,24 @@ func (file File) Contents() ([]byte, error) {
 	return io.ReadAll(in)
 }
 
-func FilesSince(since time.Time, limit int) ([]File, error) {
+func FilesAfter(since time.Time, afterFileID int64, limit int) ([]File, error) {
+	db, err := openFetcherDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return db.fetchedAfter(since, afterFileID, limit)
+}
+
+func FilesByDate(from, to time.Time, afterFileID int64, limit int) ([]File, error) {
 	db, err := openFetcherDB()
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	return db.fetchedSince(since, limit)
+	return db.fetchedByDate(from, to, afterFileID, limit)
 }
 
 func FileByID(fileID int64) (File, error) {
@@ -80,10 +103,18 @@ func FileByID(fileID int64) (File, error) {
 }
 
 func fetchFile(file File, db *fetcherDB) error {
-	fileData, err := fetch(file.url)
+	etag, lastModified := file.etag, file.lastModified
+	if _, err := os.Stat(file.Filename()); err != nil || !file.purgeTimestamp.IsZero() {
+		etag, lastModified = "", ""
+	}
+
+	result, err := fetchConditional(file.url, etag, lastModified)
 	if err != nil {
 		return err
 	}
+	if result.notModified {
+		return db.updateFileFetched(file.fileID, result.etag, result.lastModified)
+	}
 
 	dirname, filename := filename(file)
 	if err := os.MkdirAll(dirname, os.ModePerm); err != nil {
@@ -97,16 +128,17 @@ func fetchFile(file File, db *fetcherDB) error {
 	defer fd.Close()
 
 	gz := gzip.NewWriter(fd)
-	defer gz.Close()
-	if _, err := gz.Write(fileData); err != nil {
+	if _, err := gz.Write(result.data); err != nil {
 		return err
 	}
-
-	if err := db.updateFileFetched(file.fileID); err != nil {
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := fd.Close(); err != nil {
 		return err
 	}
 
-	return nil
+	return db.updateFileFetched(file.fileID, result.etag, result.lastModified)
 }
 
 func filename(file File) (string, string) {
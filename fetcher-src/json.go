@@ -0,0 +1,60 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type jsonSource struct{}
+
+func (jsonSource) Dated() bool {
+	return false
+}
+
+func (jsonSource) Links(feed Feed, date time.Time) ([]Link, error) {
+	data, err := fetch(feed.urlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	items, ok := jsonPath(doc, feed.sourceOptions["items"]).([]any)
+	if !ok {
+		return nil, fmt.Errorf("No items array at %q for feed %d", feed.sourceOptions["items"], feed.feedID)
+	}
+	urlPath := feed.sourceOptions["url"]
+	if urlPath == "" {
+		urlPath = "url"
+	}
+
+	links := []Link{}
+	for _, item := range items {
+		url, _ := jsonPath(item, urlPath).(string)
+		date, _ := jsonPath(item, feed.sourceOptions["date"]).(string)
+		links = append(links, Link{
+			URL:  url,
+			Date: parseLinkDate(feed, date),
+		})
+	}
+	return filterLinks(feed, links)
+}
+
+func jsonPath(value any, path string) any {
+	if path == "" {
+		return value
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	buckets  map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var hosts = &hostLimiter{
+	interval: 15 * time.Second,
+	burst:    1,
+	buckets:  map[string]*tokenBucket{},
+}
+
+func (l *hostLimiter) configure(interval time.Duration, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval = interval
+	l.burst = max(burst, 1)
+}
+
+func (l *hostLimiter) wait(rawURL string) {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	for {
+		delay := l.take(host)
+		if delay <= 0 {
+			return
+		}
+		time.Sleep(delay)
+	}
+}
+
+func (l *hostLimiter) take(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[host]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[host] = bucket
+	}
+	if l.interval <= 0 {
+		return 0
+	}
+	bucket.tokens = min(float64(l.burst), bucket.tokens+float64(now.Sub(bucket.last))/float64(l.interval))
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0
+	}
+	return time.Duration((1 - bucket.tokens) * float64(l.interval))
+}
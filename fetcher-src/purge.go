@@ -0,0 +1,197 @@
+package fetcher
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"language-analysis/config"
+)
+
+func PurgeCommand(store func(File) error) error {
+	age := config.String("purge-age")
+	count := config.String("purge-count")
+	budget := config.String("purge-budget")
+
+	db, err := openFetcherDB()
+	if err != nil {
+		return fmt.Errorf("Failed to open fetcher database: %v", err)
+	}
+	defer db.Close()
+
+	purged := 0
+	switch {
+	case age != "" && count == "" && budget == "":
+		d, err := time.ParseDuration(age)
+		if err != nil {
+			return fmt.Errorf("Invalid purge-age: %v", err)
+		}
+		before := time.Now().UTC().Add(-d)
+		for {
+			files, err := db.earliestFetched(before, 100)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				break
+			}
+			for _, file := range files {
+				if _, err := purge(file, db, store); err != nil {
+					return err
+				}
+				purged++
+			}
+		}
+	case age == "" && count != "" && budget == "":
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return fmt.Errorf("Invalid purge-count: %v", err)
+		}
+		files, err := db.earliestFetched(time.Now().UTC().Add(time.Minute), n)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if _, err := purge(file, db, store); err != nil {
+				return err
+			}
+			purged++
+		}
+	case age == "" && count == "" && budget != "":
+		limit, err := parseSize(budget)
+		if err != nil {
+			return fmt.Errorf("Invalid purge-budget: %v", err)
+		}
+		usage, err := diskUsage()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Disk usage: %d bytes, budget: %d bytes.\n", usage, limit)
+		for usage > limit {
+			files, err := db.earliestFetched(time.Now().UTC().Add(time.Minute), 100)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				break
+			}
+			for _, file := range files {
+				if usage <= limit {
+					break
+				}
+				size, err := purge(file, db, store)
+				if err != nil {
+					return err
+				}
+				usage -= size
+				purged++
+			}
+		}
+	default:
+		return config.Usagef("Specify one of -purge-age, -purge-count or -purge-budget")
+	}
+	fmt.Printf("Purged %d file(s).\n", purged)
+	return nil
+}
+
+func purge(file File, db *fetcherDB, store func(File) error) (int64, error) {
+	if err := store(file); err != nil {
+		return 0, fmt.Errorf("Failed to store transcript of file %d: %v", file.fileID, err)
+	}
+	size := int64(0)
+	if info, err := os.Stat(file.Filename()); err == nil {
+		size = info.Size()
+	}
+	if err := os.Remove(file.Filename()); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if _, err := db.purgeFile(file.fileID); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func diskUsage() (int64, error) {
+	usage := int64(0)
+	err := filepath.WalkDir(config.Dir()+"/files", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			usage += info.Size()
+		}
+		return nil
+	})
+	return usage, err
+}
+
+func parseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	size = strings.TrimSuffix(size, "B")
+	multiplier := int64(1)
+	for i, suffix := range []string{"K", "M", "G", "T"} {
+		if strings.HasSuffix(size, suffix) {
+			size = strings.TrimSuffix(size, suffix)
+			multiplier = int64(1) << (10 * (i + 1))
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(size, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+func RefetchCommand() error {
+	file := strings.Join(config.Args(), " ")
+	from := config.String("from")
+	to := config.String("to")
+
+	db, err := openFetcherDB()
+	if err != nil {
+		return fmt.Errorf("Failed to open fetcher database: %v", err)
+	}
+	defer db.Close()
+
+	if file != "" && from == "" && to == "" {
+		var fileID int64
+		if _, err := fmt.Sscanf(file, "%d", &fileID); err != nil {
+			return fmt.Errorf("Invalid fileID: %s", file)
+		}
+		if ok, err := db.reenqueue(fileID); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("Nonexistent fileID: %d", fileID)
+		}
+		fmt.Printf("Requeued file %d.\n", fileID)
+		return nil
+	} else if file == "" && from != "" && to != "" {
+		start, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return fmt.Errorf("Invalid from date: %v", err)
+		}
+		end, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			return fmt.Errorf("Invalid to date: %v", err)
+		}
+		count, err := db.reenqueueDateRange(start, end)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Requeued %d purged file(s).\n", count)
+		return nil
+	}
+	return config.Usagef("Specify a fileID or -from=<date> -to=<date>")
+}
@@ -0,0 +1,75 @@
+package fetcher
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type rssSource struct{}
+
+type rssDocument struct {
+	Items []struct {
+		Link    string `xml:"link"`
+		GUID    string `xml:"guid"`
+		PubDate string `xml:"pubDate"`
+		DCDate  string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	} `xml:"channel>item"`
+	Entries []struct {
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+func (rssSource) Dated() bool {
+	return false
+}
+
+func (rssSource) Links(feed Feed, date time.Time) ([]Link, error) {
+	data, err := fetch(feed.urlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := rssDocument{}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	links := []Link{}
+	for _, item := range doc.Items {
+		link := item.Link
+		if link == "" {
+			link = item.GUID
+		}
+		pubDate := item.PubDate
+		if pubDate == "" {
+			pubDate = item.DCDate
+		}
+		links = append(links, Link{
+			URL:  link,
+			Date: parseLinkDate(feed, pubDate),
+		})
+	}
+	for _, entry := range doc.Entries {
+		href := ""
+		for _, link := range entry.Links {
+			if link.Rel == "" || link.Rel == "alternate" {
+				href = link.Href
+				break
+			}
+		}
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+		links = append(links, Link{
+			URL:  href,
+			Date: parseLinkDate(feed, published),
+		})
+	}
+	return filterLinks(feed, links)
+}
@@ -0,0 +1,62 @@
+package fetcher
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type sitemapSource struct{}
+
+type sitemapDocument struct {
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+}
+
+func (sitemapSource) Dated() bool {
+	return false
+}
+
+func (sitemapSource) Links(feed Feed, date time.Time) ([]Link, error) {
+	links := []Link{}
+	if err := sitemapLinks(feed, feed.urlTemplate, 0, &links); err != nil {
+		return nil, err
+	}
+	return filterLinks(feed, links)
+}
+
+func sitemapLinks(feed Feed, url string, depth int, links *[]Link) error {
+	data, err := fetch(url)
+	if err != nil {
+		return err
+	}
+
+	doc := sitemapDocument{}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	for _, u := range doc.URLs {
+		*links = append(*links, Link{
+			URL:  u.Loc,
+			Date: parseLinkDate(feed, u.LastMod),
+		})
+	}
+	if depth > 0 {
+		return nil
+	}
+	for _, sitemap := range doc.Sitemaps {
+		if lastMod := parseLinkDate(feed, sitemap.LastMod); !lastMod.IsZero() && lastMod.Before(feed.latestFetchDate) {
+			continue
+		}
+		if err := sitemapLinks(feed, sitemap.Loc, depth+1, links); err != nil {
+			return err
+		}
+	}
+	return nil
+}
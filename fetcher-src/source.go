@@ -0,0 +1,130 @@
+package fetcher
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Link struct {
+	URL  string
+	Date time.Time
+}
+
+type Source interface {
+	Dated() bool
+	Links(feed Feed, date time.Time) ([]Link, error)
+}
+
+var sources = map[string]Source{
+	"regex-daily": regexDailySource{},
+	"rss":         rssSource{},
+	"sitemap":     sitemapSource{},
+	"json":        jsonSource{},
+}
+
+func sourceNames() []string {
+	names := []string{}
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sourceFor(feed Feed) (Source, error) {
+	name := feed.source
+	if name == "" {
+		name = "regex-daily"
+	}
+	if source, ok := sources[name]; ok {
+		return source, nil
+	}
+	return nil, fmt.Errorf("Unknown source %s for feed %d, available sources: %s", name, feed.feedID, strings.Join(sourceNames(), ", "))
+}
+
+type regexDailySource struct{}
+
+func (regexDailySource) Dated() bool {
+	return true
+}
+
+func (regexDailySource) Links(feed Feed, date time.Time) ([]Link, error) {
+	feedRegex, err := regexp.Compile(feed.scraperRx)
+	if err != nil {
+		return nil, err
+	}
+
+	feedData, err := fetch(fmt.Sprintf(feed.urlTemplate, date.Format(time.DateOnly)))
+	if isNotFound(err) {
+		fmt.Printf("No feed page: %v\n", err)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	links := []Link{}
+	for _, match := range feedRegex.FindAllSubmatchIndex(feedData, -1) {
+		if len(match) > 2*feed.scraperRxGroup+1 {
+			links = append(links, Link{
+				URL:  string(feedData[match[2*feed.scraperRxGroup]:match[2*feed.scraperRxGroup+1]]),
+				Date: date,
+			})
+		}
+	}
+	return links, nil
+}
+
+func filterLinks(feed Feed, links []Link) ([]Link, error) {
+	var linkRegex *regexp.Regexp
+	if feed.scraperRx != "" {
+		rx, err := regexp.Compile(feed.scraperRx)
+		if err != nil {
+			return nil, err
+		}
+		linkRegex = rx
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	filtered := []Link{}
+	for _, link := range links {
+		link.URL = strings.TrimSpace(link.URL)
+		if link.URL == "" {
+			continue
+		}
+		if linkRegex != nil {
+			match := linkRegex.FindStringSubmatch(link.URL)
+			if len(match) <= feed.scraperRxGroup {
+				continue
+			}
+			link.URL = match[feed.scraperRxGroup]
+		}
+		if link.Date.IsZero() {
+			link.Date = today
+		}
+		if link.Date.Before(feed.earliestDateLimit) {
+			continue
+		}
+		filtered = append(filtered, link)
+	}
+	return filtered, nil
+}
+
+func parseLinkDate(feed Feed, value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	layouts := []string{time.RFC3339, time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", "2006-01-02T15:04:05", time.DateTime, time.DateOnly}
+	if layout := feed.sourceOptions["dateLayout"]; layout != "" {
+		layouts = append([]string{layout}, layouts...)
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
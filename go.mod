@@ -5,3 +5,5 @@ go 1.24.3
 require github.com/mattn/go-sqlite3 v1.14.32
 
 require github.com/BurntSushi/toml v1.6.0
+
+require golang.org/x/net v0.47.0
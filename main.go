@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"language-analysis/config"
+	fetcher "language-analysis/fetcher-src"
+	migrations "language-analysis/migrations-src"
+	phrases "language-analysis/phrase-analysis-src"
+	scraper "language-analysis/scraper-src"
+	search "language-analysis/search-src"
+	speakers "language-analysis/speakers-src"
+	thanks "language-analysis/thank-analysis-src"
+)
+
+var filterFlags = []config.Flag{
+	config.Flag{Name: "report-feed", Value: 0, Usage: "only count files from the feed with this `id`"},
+	config.Flag{Name: "report-normalization", Value: "", Usage: "only count results collected with this `normalization`, or all (default: the configured one)"},
+	config.Flag{Name: "report-tokenizer", Value: "", Usage: "only count results collected with this `tokenizer`, or all (default: the configured one)"},
+}
+
+var thankReportFlags = append([]config.Flag{
+	config.Flag{Name: "report-format", Value: "table", Usage: "output `format`: table or csv", Choices: []string{"table", "csv"}},
+	config.Flag{Name: "report-top", Value: 20, Usage: "print at most `n` groups of words, 0 for all"},
+	config.Flag{Name: "report-min-count", Value: 1, Usage: "skip groups of words counted fewer than `n` times"},
+	config.Flag{Name: "report-trigger", Value: "", Usage: "only count thanks matching this trigger `set`"},
+}, filterFlags...)
+
+var dateFlags = []config.Flag{
+	config.Flag{Name: "from", Value: "", Usage: "earliest transcript `date`"},
+	config.Flag{Name: "to", Value: "", Usage: "latest transcript `date`"},
+}
+
+var exportFlags = append([]config.Flag{
+	config.Flag{Name: "export-format", Value: "csv", Usage: "output `format`: csv, jsonl or parquet", Choices: []string{"csv", "jsonl", "parquet"}},
+	config.Flag{Name: "export-output", Value: "", Usage: "write to `file` instead of standard output"},
+}, dateFlags...)
+
+func main() {
+	config.Main(config.Command{
+		Name:  "language-analysis",
+		Usage: "Download transcripts and analyze the language used in them.",
+		Flags: []config.Flag{
+			config.Flag{Name: "dir", Value: "./data", Usage: "`directory` for databases, downloaded files and configuration"},
+		},
+		Commands: []config.Command{
+			fetchCommand,
+			thanksCommand,
+			phrasesCommand,
+			speakersCommand,
+			searchCommand,
+			config.Command{
+				Name:    "migrate",
+				Usage:   "Inspect the database schemas.",
+				Default: "status",
+				Commands: []config.Command{
+					config.Command{
+						Name:  "status",
+						Usage: "Print the schema version of each database and its pending migrations.",
+						Run:   migrateStatusCommand,
+					},
+				},
+			},
+			config.Command{
+				Name:  "config",
+				Usage: "Inspect the configuration.",
+				Commands: []config.Command{
+					config.Command{
+						Name:  "show",
+						Usage: "Print the effective value of every option and where it came from.",
+						Run:   config.ShowCommand,
+					},
+				},
+			},
+			config.Command{
+				Name:  "show",
+				Args:  "<fileID>...",
+				Usage: "Print the transcript of each file.",
+				Run:   showCommand,
+			},
+		},
+	})
+}
+
+var fetchCommand = config.Command{
+	Name:  "fetch",
+	Usage: "Fetch transcripts, repeatedly fetching the next file.",
+	Flags: []config.Flag{
+		config.Flag{Name: "fetcher-sleep", Value: 15 * time.Second, Usage: "`time` to wait when there is nothing to fetch"},
+		config.Flag{Name: "fetcher-workers", Value: 1, Usage: "`number` of concurrent fetches"},
+		config.Flag{Name: "fetcher-host-interval", Value: time.Duration(0), Usage: "minimum `time` between requests to a host, defaults to -fetcher-sleep"},
+		config.Flag{Name: "fetcher-host-burst", Value: 1, Usage: "`number` of requests to a host allowed at once"},
+		config.Flag{Name: "fetcher-claim-timeout", Value: time.Hour, Usage: "`time` after which another worker may claim a file"},
+		config.Flag{Name: "fetcher-max-attempts", Value: 5, Usage: "`number` of failed attempts before a file is given up on"},
+		config.Flag{Name: "fetcher-retry-delay", Value: time.Hour, Usage: "`time` to wait before retrying a failed file"},
+		config.Flag{Name: "fetcher-index-interval", Value: 24 * time.Hour, Usage: "`time` between fetches of a feed's index"},
+		config.Flag{Name: "fetcher-timeout", Value: 60 * time.Second, Usage: "`time` allowed for each request"},
+		config.Flag{Name: "fetcher-retries", Value: 3, Usage: "`number` of retries of a failed request"},
+		config.Flag{Name: "fetcher-backoff", Value: 2 * time.Second, Usage: "initial `time` between retries"},
+		config.Flag{Name: "fetcher-max-backoff", Value: 5 * time.Minute, Usage: "maximum `time` between retries"},
+		config.Flag{Name: "fetcher-user-agent", Value: "language-analysis", Usage: "User-Agent `header` sent with requests"},
+	},
+	Init: func() error {
+		filename := config.Dir() + "/fetcher.toml"
+		return config.ReadConfig(filename, &fetcher.Config)
+	},
+	Run: fetcher.FetchLoopCommand,
+	Commands: []config.Command{
+		config.Command{
+			Name:  "status",
+			Usage: "Print the state of each feed.",
+			Run:   fetcher.StatusCommand,
+		},
+		config.Command{
+			Name:  "once",
+			Usage: "Fetch the next files once.",
+			Run:   fetcher.FetchCommand,
+		},
+		config.Command{
+			Name:  "add-feeds",
+			Usage: "Add or update the feeds in fetcher.toml.",
+			Run:   fetcher.AddFeedsCommand,
+		},
+		config.Command{
+			Name:  "failures",
+			Usage: "Print files that failed to fetch.",
+			Flags: []config.Flag{
+				config.Flag{Name: "failures-limit", Value: 100, Usage: "print at most `n` files"},
+			},
+			Run: fetcher.FailuresCommand,
+		},
+		config.Command{
+			Name:  "retry",
+			Args:  "<fileID>|all",
+			Usage: "Requeue failed files.",
+			Run:   fetcher.RetryCommand,
+		},
+		config.Command{
+			Name:  "purge",
+			Usage: "Delete the oldest downloaded files.",
+			Flags: []config.Flag{
+				config.Flag{Name: "purge-age", Value: "", Usage: "purge files fetched longer than `duration` ago"},
+				config.Flag{Name: "purge-count", Value: "", Usage: "purge the `n` earliest fetched files"},
+				config.Flag{Name: "purge-budget", Value: "", Usage: "purge files until the downloaded files fit in `size`"},
+			},
+			Run: func() error {
+				return fetcher.PurgeCommand(scraper.Store)
+			},
+		},
+		config.Command{
+			Name:  "refetch",
+			Args:  "[<fileID>]",
+			Usage: "Requeue a file, or purged files between -from and -to.",
+			Flags: dateFlags,
+			Run:   fetcher.RefetchCommand,
+		},
+		config.Command{
+			Name:  "extract",
+			Usage: "Store the transcript of every fetched file.",
+			Run:   scraper.ExtractCommand,
+		},
+		config.Command{
+			Name:  "transcripts",
+			Usage: "Print the number of stored transcripts.",
+			Run:   scraper.StatusCommand,
+		},
+	},
+}
+
+var thanksCommand = config.Command{
+	Name:  "thanks",
+	Usage: "Analyze responses to thanks.",
+	Init: func() error {
+		filename := config.Dir() + "/thank-analysis.toml"
+		if _, err := os.Stat(filename); err == nil {
+			if err := config.ReadConfig(filename, &thanks.Config); err != nil {
+				return err
+			}
+		}
+		if err := thanks.ConfigureTriggers(); err != nil {
+			return err
+		}
+		return thanks.ConfigureTokenizer()
+	},
+	Default: "collect",
+	Commands: []config.Command{
+		config.Command{
+			Name:  "status",
+			Usage: "Print the collection state.",
+			Run:   thanks.StatusCommand,
+		},
+		config.Command{
+			Name:  "collect",
+			Usage: "Collect responses from newly fetched files.",
+			Flags: []config.Flag{
+				config.Flag{Name: "thank-collect-count", Value: 50, Usage: "collect at most `n` files"},
+			},
+			Run: thanks.CollectCommand,
+		},
+		config.Command{
+			Name:  "report",
+			Usage: "Print the most common responses by period.",
+			Flags: append([]config.Flag{
+				config.Flag{Name: "report-period", Value: "month", Usage: "`period`: day, week, month or year", Choices: []string{"day", "week", "month", "year"}},
+				config.Flag{Name: "report-role", Value: "", Usage: "only count responses by speakers with this `role`"},
+				config.Flag{Name: "report-speaker", Value: "", Usage: "only count responses by this speaker `id|name`"},
+			}, thankReportFlags...),
+			Run: thanks.ReportCommand,
+		},
+		config.Command{
+			Name:  "exchanges",
+			Usage: "Print the most common groups of words on one side of an exchange.",
+			Flags: append([]config.Flag{
+				config.Flag{Name: "exchange-side", Value: "response", Usage: "`side` to count: response or thank", Choices: []string{"response", "thank"}},
+				config.Flag{Name: "exchange-thank", Value: "", Usage: "only count exchanges whose thanks contain `words`"},
+				config.Flag{Name: "exchange-response", Value: "", Usage: "only count exchanges whose response contains `words`"},
+			}, thankReportFlags...),
+			Run: thanks.ExchangesCommand,
+		},
+		config.Command{
+			Name:  "export",
+			Usage: "Write response counts by file and speaker.",
+			Flags: append(append([]config.Flag{
+				config.Flag{Name: "report-role", Value: "", Usage: "only count responses by speakers with this `role`"},
+				config.Flag{Name: "report-speaker", Value: "", Usage: "only count responses by this speaker `id|name`"},
+				config.Flag{Name: "report-trigger", Value: "", Usage: "only count thanks matching this trigger `set`"},
+			}, filterFlags...), exportFlags...),
+			Run: thanks.ExportCommand,
+		},
+	},
+}
+
+var phrasesCommand = config.Command{
+	Name:  "phrases",
+	Usage: "Count phrases and prefaces.",
+	Init: func() error {
+		filename := config.Dir() + "/phrase-analysis.toml"
+		if err := config.ReadConfig(filename, &phrases.Config); err != nil {
+			return err
+		}
+		return phrases.ConfigureTokenizer()
+	},
+	Default: "collect",
+	Commands: []config.Command{
+		config.Command{
+			Name:  "status",
+			Usage: "Print the collection state.",
+			Run:   phrases.StatusCommand,
+		},
+		config.Command{
+			Name:  "collect",
+			Usage: "Count phrases in newly fetched files.",
+			Flags: []config.Flag{
+				config.Flag{Name: "phrase-collect-count", Value: 500, Usage: "collect at most `n` files"},
+			},
+			Run: phrases.CollectCommand,
+		},
+		config.Command{
+			Name:  "add",
+			Usage: "Add the phrases and prefaces in phrase-analysis.toml.",
+			Run:   phrases.AddCommand,
+		},
+		config.Command{
+			Name:  "report",
+			Usage: "Print phrase and preface totals by period.",
+			Flags: append([]config.Flag{
+				config.Flag{Name: "report-period", Value: "month", Usage: "`period`: day, week, month or year", Choices: []string{"day", "week", "month", "year"}},
+				config.Flag{Name: "report-normalize", Value: "none", Usage: "`scale`: none, transcript or 10k", Choices: []string{"none", "transcript", "10k"}},
+				config.Flag{Name: "report-format", Value: "table", Usage: "output `format`: table or csv", Choices: []string{"table", "csv"}},
+				config.Flag{Name: "report-ratio", Value: "", Usage: "also print `a/b` ratios between phrases"},
+				config.Flag{Name: "report-speaker", Value: "", Usage: "only count phrases by this speaker `id|name`"},
+			}, filterFlags...),
+			Run: phrases.ReportCommand,
+		},
+		config.Command{
+			Name:  "export",
+			Usage: "Write phrase and preface counts by file and speaker.",
+			Flags: append(append([]config.Flag{
+				config.Flag{Name: "report-speaker", Value: "", Usage: "only count phrases by this speaker `id|name`"},
+			}, filterFlags...), exportFlags...),
+			Run: phrases.ExportCommand,
+		},
+		config.Command{
+			Name:  "concordance",
+			Args:  "<phrase>",
+			Usage: "Print every use of a phrase in context.",
+			Flags: append([]config.Flag{
+				config.Flag{Name: "concordance-context", Value: 8, Usage: "`number` of words printed on either side"},
+				config.Flag{Name: "concordance-limit", Value: 0, Usage: "stop after `n` matches, 0 for all"},
+				config.Flag{Name: "concordance-format", Value: "text", Usage: "output `format`: text or json", Choices: []string{"text", "json"}},
+			}, dateFlags...),
+			Run: phrases.ConcordanceCommand,
+		},
+	},
+}
+
+var speakersCommand = config.Command{
+	Name:    "speakers",
+	Usage:   "Manage the shared speaker registry.",
+	Default: "list",
+	Commands: []config.Command{
+		config.Command{
+			Name:  "list",
+			Usage: "Print each speaker with its aliases.",
+			Run:   speakers.ListCommand,
+		},
+		config.Command{
+			Name:  "merge",
+			Args:  "<id|name> <id|name>",
+			Usage: "Fold the first speaker into the second.",
+			Run:   speakers.MergeCommand,
+		},
+		config.Command{
+			Name:  "split",
+			Args:  "<alias>",
+			Usage: "Move an alias out into its own speaker.",
+			Run:   speakers.SplitCommand,
+		},
+	},
+}
+
+var searchCommand = config.Command{
+	Name:    "search",
+	Usage:   "Index and search transcript turns.",
+	Default: "index",
+	Commands: []config.Command{
+		config.Command{
+			Name:  "status",
+			Usage: "Print the state of the index.",
+			Run:   search.StatusCommand,
+		},
+		config.Command{
+			Name:  "index",
+			Usage: "Index newly fetched files.",
+			Flags: []config.Flag{
+				config.Flag{Name: "search-index-count", Value: 1000, Usage: "index at most `n` files"},
+			},
+			Run: search.IndexCommand,
+		},
+		config.Command{
+			Name:  "query",
+			Args:  "<query>",
+			Usage: "Print the turns matching a query.",
+			Flags: append([]config.Flag{
+				config.Flag{Name: "search-format", Value: "text", Usage: "output `format`: text or json", Choices: []string{"text", "json"}},
+				config.Flag{Name: "search-limit", Value: 100, Usage: "print at most `n` turns, 0 for all"},
+				config.Flag{Name: "speaker", Value: "", Usage: "only search turns by this speaker `id|name`"},
+			}, dateFlags...),
+			Run: search.SearchCommand,
+		},
+	},
+}
+
+func migrateStatusCommand() error {
+	return migrations.StatusCommand(fetcher.Schema, scraper.Schema, speakers.Schema, thanks.Schema, phrases.Schema, search.Schema)
+}
+
+func showCommand() error {
+	if len(config.Args()) == 0 {
+		return config.Usagef("Specify a fileID")
+	}
+	for _, arg := range config.Args() {
+		var fileID int64
+		if _, err := fmt.Sscanf(arg, "%d", &fileID); err != nil {
+			return config.Usagef("Invalid fileID: %s", arg)
+		} else if file, err := fetcher.FileByID(fileID); err != nil {
+			return err
+		} else if content, err := scraper.Load(file); err != nil {
+			return err
+		} else {
+			fmt.Printf("%s %d\n", file.Date().Format(time.DateOnly), file.ID())
+			for _, line := range content {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+	}
+	return nil
+}
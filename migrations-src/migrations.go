@@ -0,0 +1,152 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"language-analysis/config"
+)
+
+type Migration struct {
+	Name       string
+	Probe      string
+	Statements []string
+}
+
+type Database struct {
+	Name       string
+	Filename   string
+	Migrations []Migration
+}
+
+func version(db *sql.DB) (int, error) {
+	var v int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&v); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+func (d Database) Apply(db *sql.DB) error {
+	v, err := version(db)
+	if err != nil {
+		return err
+	}
+	if v > len(d.Migrations) {
+		return fmt.Errorf("Database %s is at version %d, newer than the latest known version %d", d.Name, v, len(d.Migrations))
+	}
+
+	if v == 0 {
+		return d.migrate(db, 0, len(d.Migrations), true)
+	}
+	for n := v; n < len(d.Migrations); n++ {
+		if err := d.migrate(db, n, n+1, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d Database) migrate(db *sql.DB, from, to int, unversioned bool) error {
+	if from == to {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for n := from; n < to; n++ {
+		m := d.Migrations[n]
+		if unversioned && m.Probe != "" && probe(tx, m.Probe) {
+			continue
+		}
+		for _, statement := range m.Statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return fmt.Errorf("Failed to migrate %s to version %d (%s): %v", d.Name, n+1, m.Name, err)
+			}
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", to)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func probe(tx *sql.Tx, query string) bool {
+	_, err := tx.Exec(query)
+	return err == nil
+}
+
+type status struct {
+	version  int
+	detected int
+	pending  []string
+}
+
+func (d Database) status(db *sql.DB) (status, error) {
+	v, err := version(db)
+	if err != nil {
+		return status{}, err
+	}
+	s := status{version: v}
+	if v > 0 {
+		for _, m := range d.Migrations[min(v, len(d.Migrations)):] {
+			s.pending = append(s.pending, m.Name)
+		}
+		return s, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return status{}, err
+	}
+	defer tx.Rollback()
+	for _, m := range d.Migrations {
+		if m.Probe != "" && probe(tx, m.Probe) {
+			s.detected++
+		} else {
+			s.pending = append(s.pending, m.Name)
+		}
+	}
+	return s, nil
+}
+
+func StatusCommand(databases ...Database) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(out, "database\tfile\tversion\tlatest\tpending\t\n")
+	for _, d := range databases {
+		filename := config.Dir() + "/" + d.Filename
+		if _, err := os.Stat(filename); err != nil {
+			fmt.Fprintf(out, "%s\t%s\tnone\t%d\tnot created\t\n", d.Name, d.Filename, len(d.Migrations))
+			continue
+		}
+
+		db, err := sql.Open("sqlite3", "file:"+filename+"?mode=ro")
+		if err != nil {
+			return fmt.Errorf("Failed to open %s: %v", filename, err)
+		}
+		s, err := d.status(db)
+		db.Close()
+		if err != nil {
+			return fmt.Errorf("Failed to read the version of %s: %v", filename, err)
+		}
+
+		current := fmt.Sprintf("%d", s.version)
+		if s.version == 0 && s.detected > 0 {
+			current = fmt.Sprintf("unversioned, %d detected", s.detected)
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%d\t%d\t\n", d.Name, d.Filename, current, len(d.Migrations), len(s.pending))
+		for _, name := range s.pending {
+			fmt.Fprintf(out, "\t\t\t\t  %s\t\n", name)
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,337 @@
+package migrations_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	fetcher "language-analysis/fetcher-src"
+	migrations "language-analysis/migrations-src"
+	phraseAnalysis "language-analysis/phrase-analysis-src"
+	thankAnalysis "language-analysis/thank-analysis-src"
+)
+
+var baselineFetcher = []string{
+	`CREATE TABLE feeds (
+		feedID INTEGER PRIMARY KEY AUTOINCREMENT,
+		urlTemplate TEXT UNIQUE,
+		scraperRx TEXT,
+		scraperRxGroup INTEGER,
+		earliestDateLimit DATE,
+
+		earliestFetchDate DATE,
+		earliestFetchDateTimestamp TIMESTAMP,
+		latestFetchDate DATE,
+		latestFetchDateTimestamp TIMESTAMP)`,
+	`CREATE TABLE files (
+		fileID INTEGER PRIMARY KEY AUTOINCREMENT,
+		feedID INTEGER REFERENCES feed (feedID),
+		url TEXT UNIQUE,
+		date DATE,
+		fetchTimestamp TIMESTAMP,
+		purgeTimestamp TIMESTAMP)`,
+	`CREATE INDEX filesFetchTimestamp ON files (fetchTimestamp)`,
+	`CREATE INDEX filesPurgeTimestamp ON files (purgeTimestamp)`,
+	`CREATE INDEX filesFeedIDFetchTimestamp ON files (feedID, fetchTimestamp)`,
+	`CREATE INDEX filesFeedIDPurgeTimestamp ON files (feedID, purgeTimestamp)`,
+	`CREATE INDEX filesDate ON files (date)`,
+	`CREATE INDEX filesDatePurgeTimestamp ON files (date, purgeTimestamp)`,
+}
+
+var baselinePhrases = []string{
+	`CREATE TABLE phrases (
+		phraseID INTEGER PRIMARY KEY AUTOINCREMENT,
+		phrase TEXT UNIQUE NOT NULL,
+		lastFetchTimestamp TIMESTAMP)`,
+	`CREATE INDEX phrasesPhrase ON phrases (phrase)`,
+	`CREATE INDEX phrasesLastFetchTimestamp ON phrases (lastFetchTimestamp)`,
+	`CREATE TABLE prefaces (
+		prefaceID INTEGER PRIMARY KEY AUTOINCREMENT,
+		preface TEXT UNIQUE NOT NULL,
+		lastFetchTimestamp TIMESTAMP)`,
+	`CREATE INDEX prefacesPreface ON prefaces (preface)`,
+	`CREATE INDEX prefacesLastFetchTimestamp ON prefaces (lastFetchTimestamp)`,
+	`CREATE TABLE speakers (
+		speakerID INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE)`,
+	`CREATE INDEX speakersName ON speakers (name)`,
+	`CREATE TABLE files (
+		fileID INTEGER PRIMARY KEY,
+		date DATE)`,
+	`CREATE INDEX fileDate ON files (date)`,
+	`CREATE TABLE phraseCounts (
+		fileID INTEGER REFERENCES files (fileID),
+		speakerID INTEGER REFERENCES speakers (speakerID),
+		phraseID INTEGER REFERENCES phrases (phraseID),
+		count INTEGER)`,
+	`CREATE INDEX phraseCountsSpeakerID ON phraseCounts (speakerID)`,
+	`CREATE INDEX phraseCountsPhraseID ON phraseCounts (phraseID)`,
+	`CREATE TABLE prefaceCounts (
+		fileID INTEGER REFERENCES files (fileID),
+		speakerID INTEGER REFERENCES speakers (speakerID),
+		prefaceID INTEGER REFERENCES prefaces (prefaceID),
+		count INTEGER)`,
+	`CREATE INDEX prefaceCountsSpeakerID ON prefaceCounts (speakerID)`,
+	`CREATE INDEX prefaceCountsPrefaceID ON prefaceCounts (prefaceID)`,
+}
+
+var baselineThanks = []string{
+	`CREATE TABLE fetcherState (
+		lastFetchTimestamp TIMESTAMP)`,
+	`INSERT INTO fetcherState (lastFetchTimestamp)
+		VALUES ('1970-01-01 00:00:00')`,
+	`CREATE TABLE words (
+		wordID INTEGER PRIMARY KEY AUTOINCREMENT,
+		word TEXT UNIQUE)`,
+	`CREATE TABLE speakers (
+		speakerID INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE)`,
+	`CREATE INDEX speakersName ON speakers (name)`,
+	`CREATE TABLE files (
+		fileID INTEGER PRIMARY KEY,
+		date DATE)`,
+	`CREATE INDEX fileDate ON files (date)`,
+	`CREATE TABLE responses (
+		fileID INTEGER REFERENCES files (fileID),
+		speakerID INTEGER REFERENCES speakers (speakerID),
+		word1ID INTEGER REFERENCES words (wordID),
+		word2ID INTEGER REFERENCES words (wordID),
+		word3ID INTEGER REFERENCES words (wordID),
+		word4ID INTEGER REFERENCES words (wordID),
+		word5ID INTEGER REFERENCES words (wordID),
+		PRIMARY KEY (fileID, speakerID,
+			word1ID, word2ID, word3ID, word4ID, word5ID))`,
+	`CREATE INDEX responsesWords
+		ON responses (word1ID, word2ID, word3ID,
+				word4ID, word5ID)`,
+}
+
+func openDB(t *testing.T, name string, statements ...string) *sql.DB {
+	db, err := sql.Open("sqlite3", t.TempDir()+"/"+name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to run %q: %v", statement, err)
+		}
+	}
+	return db
+}
+
+func userVersion(t *testing.T, db *sql.DB) int {
+	var v int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func columns(t *testing.T, db *sql.DB) string {
+	rows, err := db.Query(`SELECT m.name, p.name, p.type, p."notnull", IFNULL(p.dflt_value, '')
+		FROM sqlite_master m, pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name, p.cid`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	lines := []string{}
+	for rows.Next() {
+		var table, column, typ, notNull, dflt string
+		if err := rows.Scan(&table, &column, &typ, &notNull, &dflt); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.Join([]string{table, column, typ, notNull, dflt}, " "))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func indexes(t *testing.T, db *sql.DB) string {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'index' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestUpgradeFromBaseline(t *testing.T) {
+	tests := []struct {
+		schema   migrations.Database
+		baseline []string
+		rows     []string
+		checks   map[string]string
+	}{
+		{
+			schema:   fetcher.Schema,
+			baseline: baselineFetcher,
+			rows: []string{
+				`INSERT INTO feeds (urlTemplate, earliestFetchDate) VALUES ('https://example.com/%s', '2020-01-01')`,
+				`INSERT INTO files (feedID, url, date, fetchTimestamp) VALUES (1, 'https://example.com/a', '2020-01-02', '2020-01-03 04:05:06')`,
+			},
+			checks: map[string]string{
+				"SELECT urlTemplate || '|' || IFNULL(source, 'null') || '|' || IFNULL(extractStart, 'null') FROM feeds":                                     "https://example.com/%s|null|null",
+				"SELECT url || '|' || fetchTimestamp || '|' || attempts || '|' || IFNULL(etag, 'null') || '|' || IFNULL(claimTimestamp, 'null') FROM files": "https://example.com/a|2020-01-03 04:05:06|0|null|null",
+			},
+		},
+		{
+			schema:   phraseAnalysis.Schema,
+			baseline: baselinePhrases,
+			rows: []string{
+				`INSERT INTO phrases (phrase) VALUES ('bucket list')`,
+				`INSERT INTO phrases (phrase) VALUES ('glob:a perfect storm of *')`,
+				`INSERT INTO prefaces (preface) VALUES ('re:look')`,
+				`INSERT INTO speakers (name) VALUES ('HOST')`,
+				`INSERT INTO files (fileID, date) VALUES (7, '2020-01-02')`,
+				`INSERT INTO phraseCounts (fileID, speakerID, phraseID, count) VALUES (7, 1, 1, 3)`,
+			},
+			checks: map[string]string{
+				"SELECT group_concat(phrase || '=' || kind, ',') FROM (SELECT * FROM phrases ORDER BY phraseID)": "bucket list=literal,glob:a perfect storm of *=glob",
+				"SELECT preface || '=' || kind FROM prefaces":                                                    "re:look=regex",
+				"SELECT count || '|' || normalization || '|' || tokenizer FROM phraseCounts":                     "3|raw|compat",
+				"SELECT fileID || '|' || date || '|' || IFNULL(wordCount, 'null') FROM files":                    "7|2020-01-02|null",
+				"SELECT name || '|' || IFNULL(registryID, 'null') FROM speakers":                                 "HOST|null",
+			},
+		},
+		{
+			schema:   thankAnalysis.Schema,
+			baseline: baselineThanks,
+			rows: []string{
+				`UPDATE fetcherState SET lastFetchTimestamp = '2020-01-03 04:05:06'`,
+				`INSERT INTO words (word) VALUES ('you'), ('bet')`,
+				`INSERT INTO speakers (name) VALUES ('GUEST')`,
+				`INSERT INTO files (fileID, date) VALUES (7, '2020-01-02')`,
+				`INSERT INTO responses VALUES (7, 1, 1, 2, 0, 0, 0)`,
+			},
+			checks: map[string]string{
+				"SELECT lastFetchTimestamp || '' FROM fetcherState":                                                                     "2020-01-03 04:05:06",
+				"SELECT word1ID || '|' || word2ID || '|' || triggerName FROM responses JOIN responseTriggers USING (fileID, speakerID)": "1|2|thanks",
+				"SELECT fileID || '|' || normalization || '|' || tokenizer FROM files":                                                  "7|raw|compat",
+				"SELECT COUNT(*) FROM exchanges":                                                                                        "0",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.schema.Name, func(t *testing.T) {
+			db := openDB(t, "baseline.db", append(test.baseline, test.rows...)...)
+			if err := test.schema.Apply(db); err != nil {
+				t.Fatalf("Failed to upgrade the baseline schema: %v", err)
+			}
+			if v := userVersion(t, db); v != len(test.schema.Migrations) {
+				t.Errorf("user_version = %d, want %d", v, len(test.schema.Migrations))
+			}
+			for query, want := range test.checks {
+				var got string
+				if err := db.QueryRow(query).Scan(&got); err != nil {
+					t.Errorf("%s: %v", query, err)
+				} else if got != want {
+					t.Errorf("%s = %q, want %q", query, got, want)
+				}
+			}
+
+			fresh := openDB(t, "fresh.db")
+			if err := test.schema.Apply(fresh); err != nil {
+				t.Fatalf("Failed to create a fresh database: %v", err)
+			}
+			if got, want := columns(t, db), columns(t, fresh); got != want {
+				t.Errorf("Upgraded columns:\n%s\nwant:\n%s", got, want)
+			}
+			if got, want := indexes(t, db), indexes(t, fresh); got != want {
+				t.Errorf("Upgraded indexes: %s, want %s", got, want)
+			}
+
+			if err := test.schema.Apply(db); err != nil {
+				t.Errorf("Failed to reapply migrations: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpgradePartiallyUpgradedUnversioned(t *testing.T) {
+	db := openDB(t, "partial.db", append(baselineFetcher,
+		`ALTER TABLE files ADD COLUMN claimTimestamp TIMESTAMP`,
+		`ALTER TABLE feeds ADD COLUMN source TEXT`,
+		`ALTER TABLE feeds ADD COLUMN sourceOptions TEXT`,
+		`INSERT INTO files (url, claimTimestamp) VALUES ('https://example.com/a', '2020-01-03 04:05:06')`,
+	)...)
+	if err := fetcher.Schema.Apply(db); err != nil {
+		t.Fatalf("Failed to upgrade a partially upgraded database: %v", err)
+	}
+	if v := userVersion(t, db); v != len(fetcher.Schema.Migrations) {
+		t.Errorf("user_version = %d, want %d", v, len(fetcher.Schema.Migrations))
+	}
+	var claim string
+	var attempts int
+	if err := db.QueryRow("SELECT claimTimestamp, attempts FROM files").Scan(&claim, &attempts); err != nil {
+		t.Fatal(err)
+	}
+	if claim != "2020-01-03T04:05:06Z" || attempts != 0 {
+		t.Errorf("Existing file has claimTimestamp %q and attempts %d", claim, attempts)
+	}
+
+	fresh := openDB(t, "fresh.db")
+	if err := fetcher.Schema.Apply(fresh); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := columns(t, db), columns(t, fresh); got != want {
+		t.Errorf("Upgraded columns:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+var testSchema = migrations.Database{
+	Name: "test",
+	Migrations: []migrations.Migration{
+		{Name: "Create items", Probe: "SELECT itemID FROM items LIMIT 1", Statements: []string{`CREATE TABLE items (itemID INTEGER PRIMARY KEY)`}},
+		{Name: "Add item names", Probe: "SELECT name FROM items LIMIT 1", Statements: []string{`ALTER TABLE items ADD COLUMN name TEXT`}},
+		{Name: "Add item sizes", Statements: []string{`ALTER TABLE items ADD COLUMN size INTEGER`, `CREATE TABLE broken (`}},
+	},
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	db := openDB(t, "failed.db")
+	if err := testSchema.Apply(db); err == nil {
+		t.Fatal("Applied a broken migration")
+	}
+	if v := userVersion(t, db); v != 0 {
+		t.Errorf("user_version = %d after a failed unversioned upgrade, want 0", v)
+	}
+	if _, err := db.Exec("SELECT itemID FROM items"); err == nil {
+		t.Errorf("Earlier migrations were kept after a failed unversioned upgrade")
+	}
+
+	db = openDB(t, "versioned.db", `CREATE TABLE items (itemID INTEGER PRIMARY KEY)`, `PRAGMA user_version = 1`)
+	if err := testSchema.Apply(db); err == nil {
+		t.Fatal("Applied a broken migration")
+	}
+	if v := userVersion(t, db); v != 2 {
+		t.Errorf("user_version = %d after a failed versioned upgrade, want 2", v)
+	}
+	if _, err := db.Exec("SELECT size FROM items"); err == nil {
+		t.Errorf("The failed migration was partly kept")
+	}
+}
+
+func TestNewerVersionRefused(t *testing.T) {
+	db := openDB(t, "newer.db", `CREATE TABLE items (itemID INTEGER PRIMARY KEY, name TEXT, size INTEGER, color TEXT)`, `PRAGMA user_version = 4`)
+	err := testSchema.Apply(db)
+	if err == nil || !strings.Contains(err.Error(), "newer than the latest known version 3") {
+		t.Errorf("Apply on a newer database returned %v", err)
+	}
+	if v := userVersion(t, db); v != 4 {
+		t.Errorf("user_version = %d, want 4", v)
+	}
+}
@@ -1,12 +1,16 @@
 package phraseAnalysis
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
+	attach "language-analysis/attach-src"
 	"language-analysis/config"
-	fetcher "language-analysis/fetcher-src"
 	scraper "language-analysis/scraper-src"
+	speakers "language-analysis/speakers-src"
 )
 
 func StatusCommand() error {
@@ -16,12 +20,12 @@ func StatusCommand() error {
 	}
 	defer db.Close()
 
-	fetchTimestamp, err := db.lastFetchTimestamp()
+	fetchTimestamp, fileID, err := db.fetchCursor()
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Last fetch timestamp: %s\n", fetchTimestamp.Format(time.DateTime))
+	fmt.Printf("Last fetch timestamp: %s, file %d\n", fetchTimestamp.Format(time.DateTime), fileID)
 
 	phraseStatus := map[string]int{}
 	prefaceStatus := map[string]int{}
@@ -72,10 +76,7 @@ func StatusCommand() error {
 }
 
 func CollectCommand() error {
-	count, err := config.Int("phrase-collect-count", 1000)
-	if err != nil {
-		return err
-	}
+	count := config.Int("phrase-collect-count")
 
 	db, err := openPhraseDB()
 	if err != nil {
@@ -83,15 +84,20 @@ func CollectCommand() error {
 	}
 	defer db.Close()
 
+	if err := db.syncSpeakers(); err != nil {
+		return err
+	}
+
 	phraseTotals := 0
 	prefaceTotals := 0
+	compiled := map[string]pattern{}
 	for range count {
-		fetchTimestamp, err := db.lastFetchTimestamp()
+		fetchTimestamp, fileID, err := db.fetchCursor()
 		if err != nil {
 			return err
 		}
 
-		files, err := fetcher.FilesSince(fetchTimestamp, 1)
+		files, err := attach.FilesAfter(db.db, fetchTimestamp, fileID, 1)
 		if err != nil {
 			return err
 		}
@@ -101,22 +107,37 @@ func CollectCommand() error {
 			return nil
 		}
 
-		content, err := scraper.Scrape(files[0])
+		content, err := scraper.Load(files[0])
+		if errors.Is(err, scraper.ErrPurged) {
+			fmt.Printf("%s,%d: %v\n", files[0].Date().Format(time.DateOnly), files[0].ID(), err)
+			if err := db.setFetchCursor(files[0].FetchTimestamp(), files[0].ID()); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		phrases, prefaces, err := db.unfetchedPhrasesPrefaces(files[0].FetchTimestamp(), files[0].ID())
 		if err != nil {
 			return err
 		}
 
-		phrases, prefaces, err := db.unfetchedPhrasesPrefaces(files[0].FetchTimestamp())
+		roles := scraper.Roles(content)
+		registryIDs, err := speakers.Resolve(slices.Collect(maps.Keys(roles)))
 		if err != nil {
 			return err
 		}
 
-		phraseCounts, prefaceCounts := CountPhrases(content, phrases, prefaces)
-		if err := db.addCounts(files[0].ID(), files[0].Date(), phrases, prefaces, phraseCounts, prefaceCounts); err != nil {
+		phraseCounts, prefaceCounts, err := CountPhrases(content, phrases, prefaces, compiled)
+		if err != nil {
+			return err
+		}
+		if err := db.addCounts(files[0].ID(), files[0].Date(), CountWords(content), analyzer.NormalizationName(), analyzer.TokenizerName(), roles, registryIDs, phrases, prefaces, phraseCounts, prefaceCounts); err != nil {
 			return err
 		}
 
-		if err := db.setFetchTimestamp(files[0].FetchTimestamp()); err != nil {
+		if err := db.setFetchCursor(files[0].FetchTimestamp(), files[0].ID()); err != nil {
 			return err
 		}
 
@@ -0,0 +1,135 @@
+package phraseAnalysis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"language-analysis/config"
+	fetcher "language-analysis/fetcher-src"
+	scraper "language-analysis/scraper-src"
+)
+
+type concordanceLine struct {
+	Date    string `json:"date"`
+	FileID  int64  `json:"fileID"`
+	Index   int    `json:"index"`
+	Speaker string `json:"speaker"`
+	Left    string `json:"left"`
+	Match   string `json:"match"`
+	Right   string `json:"right"`
+}
+
+func ConcordanceCommand() error {
+	phrase := strings.Join(config.Args(), " ")
+	if phrase == "" {
+		return config.Usagef("Specify a phrase")
+	}
+	p, err := compilePattern(phrase)
+	if err != nil {
+		return err
+	}
+	context := config.Int("concordance-context")
+	limit := config.Int("concordance-limit")
+	format := config.String("concordance-format")
+
+	from, to := time.Time{}, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	if s := config.String("from"); s != "" {
+		if from, err = time.Parse(time.DateOnly, s); err != nil {
+			return fmt.Errorf("Invalid from date: %v", err)
+		}
+	}
+	if s := config.String("to"); s != "" {
+		if to, err = time.Parse(time.DateOnly, s); err != nil {
+			return fmt.Errorf("Invalid to date: %v", err)
+		}
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	count := 0
+	var afterFileID int64
+	for {
+		files, err := fetcher.FilesByDate(from, to, afterFileID, 100)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			break
+		}
+		for _, file := range files {
+			afterFileID = file.ID()
+			content, err := scraper.Load(file)
+			if errors.Is(err, scraper.ErrPurged) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			for _, ts := range content {
+				for _, line := range concordance(p, ts.Text, context) {
+					line.Date = file.Date().Format(time.DateOnly)
+					line.FileID = file.ID()
+					line.Index = ts.Index
+					line.Speaker = ts.Name
+					if format == "json" {
+						if err := out.Encode(line); err != nil {
+							return err
+						}
+					} else {
+						fmt.Printf("%s,%d.%d: [%s] %s\n", line.Date, line.FileID, line.Index, line.Speaker, strings.TrimSpace(line.Left+" >>"+line.Match+"<< "+line.Right))
+					}
+					count++
+					if limit > 0 && count >= limit {
+						return nil
+					}
+				}
+			}
+		}
+	}
+	if format == "text" {
+		fmt.Printf("%d match(es).\n", count)
+	}
+	return nil
+}
+
+func concordance(p pattern, text string, context int) []concordanceLine {
+	tokens := analyzer.Tokens(text)
+	sentences, indexes := scraper.SentenceWords(tokens)
+	words := []int{}
+	for _, index := range indexes {
+		words = append(words, index...)
+	}
+
+	lines := []concordanceLine{}
+	offset := 0
+	for _, sentence := range sentences {
+		for _, m := range p.matches(sentence, false) {
+			if m[0] >= m[1] {
+				continue
+			}
+			first, last := offset+m[0], offset+m[1]-1
+			left, right := max(0, first-context), min(len(words)-1, last+context)
+			start, end := tokens[words[first]].Start, tokens[words[last]].End
+			leftStart, rightEnd := tokens[words[left]].Start, tokens[words[right]].End
+			if left == 0 {
+				leftStart = 0
+			}
+			if right == len(words)-1 {
+				rightEnd = len(text)
+			}
+			lines = append(lines, concordanceLine{
+				Left:  contextText(text[leftStart:start]),
+				Match: contextText(text[start:end]),
+				Right: contextText(text[end:rightEnd]),
+			})
+		}
+		offset += len(sentence)
+	}
+	return lines
+}
+
+func contextText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
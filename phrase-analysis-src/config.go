@@ -1,6 +1,24 @@
 package phraseAnalysis
 
+import (
+	scraper "language-analysis/scraper-src"
+)
+
 var Config struct {
-	Phrases  []string
-	Prefaces []string
+	Phrases       []string
+	Prefaces      []string
+	Normalization string
+	Variants      map[string]string
+	Tokenizer     string
+}
+
+var analyzer scraper.Analyzer
+
+func ConfigureTokenizer() error {
+	a, err := scraper.MakeAnalyzer(Config.Tokenizer, Config.Normalization, Config.Variants)
+	if err != nil {
+		return err
+	}
+	analyzer = a
+	return nil
 }
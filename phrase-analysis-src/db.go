@@ -3,19 +3,136 @@ package phraseAnalysis
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 
+	attach "language-analysis/attach-src"
 	"language-analysis/config"
+	migrations "language-analysis/migrations-src"
+	report "language-analysis/report-src"
+	speakers "language-analysis/speakers-src"
 )
 
+var Schema = migrations.Database{
+	Name:     "phrases",
+	Filename: "phrase-analysis.db",
+	Migrations: []migrations.Migration{
+		migrations.Migration{
+			Name:  "Create phrases, prefaces and counts",
+			Probe: "SELECT phraseID FROM phrases LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE phrases (
+					phraseID INTEGER PRIMARY KEY AUTOINCREMENT,
+					phrase TEXT UNIQUE NOT NULL,
+					lastFetchTimestamp TIMESTAMP)`,
+				`CREATE INDEX phrasesPhrase ON phrases (phrase)`,
+				`CREATE INDEX phrasesLastFetchTimestamp ON phrases (lastFetchTimestamp)`,
+				`CREATE TABLE prefaces (
+					prefaceID INTEGER PRIMARY KEY AUTOINCREMENT,
+					preface TEXT UNIQUE NOT NULL,
+					lastFetchTimestamp TIMESTAMP)`,
+				`CREATE INDEX prefacesPreface ON prefaces (preface)`,
+				`CREATE INDEX prefacesLastFetchTimestamp ON prefaces (lastFetchTimestamp)`,
+				`CREATE TABLE speakers (
+					speakerID INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT UNIQUE)`,
+				`CREATE INDEX speakersName ON speakers (name)`,
+				`CREATE TABLE files (
+					fileID INTEGER PRIMARY KEY,
+					date DATE)`,
+				`CREATE INDEX fileDate ON files (date)`,
+				`CREATE TABLE phraseCounts (
+					fileID INTEGER REFERENCES files (fileID),
+					speakerID INTEGER REFERENCES speakers (speakerID),
+					phraseID INTEGER REFERENCES phrases (phraseID),
+					count INTEGER)`,
+				`CREATE INDEX phraseCountsSpeakerID ON phraseCounts (speakerID)`,
+				`CREATE INDEX phraseCountsPhraseID ON phraseCounts (phraseID)`,
+				`CREATE TABLE prefaceCounts (
+					fileID INTEGER REFERENCES files (fileID),
+					speakerID INTEGER REFERENCES speakers (speakerID),
+					prefaceID INTEGER REFERENCES prefaces (prefaceID),
+					count INTEGER)`,
+				`CREATE INDEX prefaceCountsSpeakerID ON prefaceCounts (speakerID)`,
+				`CREATE INDEX prefaceCountsPrefaceID ON prefaceCounts (prefaceID)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add file word counts",
+			Probe: "SELECT wordCount FROM files LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE files ADD COLUMN wordCount INTEGER`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add phrase kinds",
+			Probe: "SELECT kind FROM phrases LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE phrases ADD COLUMN kind TEXT NOT NULL DEFAULT 'literal'`,
+				`ALTER TABLE prefaces ADD COLUMN kind TEXT NOT NULL DEFAULT 'literal'`,
+				`UPDATE phrases SET kind = 'regex' WHERE phrase LIKE 're:%'`,
+				`UPDATE phrases SET kind = 'glob' WHERE phrase LIKE 'glob:%'`,
+				`UPDATE prefaces SET kind = 'regex' WHERE preface LIKE 're:%'`,
+				`UPDATE prefaces SET kind = 'glob' WHERE preface LIKE 'glob:%'`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add count normalization",
+			Probe: "SELECT normalization FROM phraseCounts LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE phraseCounts ADD COLUMN normalization TEXT NOT NULL DEFAULT 'raw'`,
+				`ALTER TABLE prefaceCounts ADD COLUMN normalization TEXT NOT NULL DEFAULT 'raw'`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add count tokenizer",
+			Probe: "SELECT tokenizer FROM phraseCounts LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE phraseCounts ADD COLUMN tokenizer TEXT NOT NULL DEFAULT 'compat'`,
+				`ALTER TABLE prefaceCounts ADD COLUMN tokenizer TEXT NOT NULL DEFAULT 'compat'`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add file speaker roles",
+			Probe: "SELECT role FROM fileSpeakers LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE fileSpeakers (
+					fileID INTEGER REFERENCES files (fileID),
+					speakerID INTEGER REFERENCES speakers (speakerID),
+					role TEXT,
+					PRIMARY KEY (fileID, speakerID))`,
+				`CREATE INDEX fileSpeakersRole ON fileSpeakers (role)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add speaker registry IDs",
+			Probe: "SELECT registryID FROM speakers LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE speakers ADD COLUMN registryID INTEGER`,
+				`CREATE INDEX speakersRegistryID ON speakers (registryID)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add fetch cursor file IDs",
+			Probe: "SELECT lastFileID FROM phrases LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE phrases ADD COLUMN lastFileID INTEGER NOT NULL DEFAULT 0`,
+				`ALTER TABLE prefaces ADD COLUMN lastFileID INTEGER NOT NULL DEFAULT 0`,
+				`UPDATE phrases SET lastFileID = (SELECT COALESCE(MAX(fileID), 0) FROM files)`,
+				`UPDATE prefaces SET lastFileID = (SELECT COALESCE(MAX(fileID), 0) FROM files)`,
+			},
+		},
+	},
+}
+
 type phraseDB struct {
 	db *sql.DB
 }
 
 func openPhraseDB() (*phraseDB, error) {
-	db, err := sql.Open("sqlite3", config.Dir()+"/phrase-analysis.db")
+	db, err := sql.Open(attach.Driver, config.Dir()+"/"+Schema.Filename)
 	if err != nil {
 		return nil, err
 	}
@@ -33,58 +150,7 @@ func (db *phraseDB) Close() error {
 }
 
 func (db *phraseDB) init() error {
-	if _, err := db.db.Query("SELECT phraseID FROM phrases LIMIT 1"); err == nil {
-		return nil
-	}
-
-	tx, err := db.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	for _, statement := range []string{
-		`CREATE TABLE phrases (
-			phraseID INTEGER PRIMARY KEY AUTOINCREMENT,
-			phrase TEXT UNIQUE NOT NULL,
-			lastFetchTimestamp TIMESTAMP)`,
-		`CREATE INDEX phrasesPhrase ON phrases (phrase)`,
-		`CREATE INDEX phrasesLastFetchTimestamp ON phrases (lastFetchTimestamp)`,
-		`CREATE TABLE prefaces (
-			prefaceID INTEGER PRIMARY KEY AUTOINCREMENT,
-			preface TEXT UNIQUE NOT NULL,
-			lastFetchTimestamp TIMESTAMP)`,
-		`CREATE INDEX prefacesPreface ON prefaces (preface)`,
-		`CREATE INDEX prefacesLastFetchTimestamp ON prefaces (lastFetchTimestamp)`,
-		`CREATE TABLE speakers (
-			speakerID INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE)`,
-		`CREATE INDEX speakersName ON speakers (name)`,
-		`CREATE TABLE files (
-			fileID INTEGER PRIMARY KEY,
-			date DATE)`,
-		`CREATE INDEX fileDate ON files (date)`,
-		`CREATE TABLE phraseCounts (
-			fileID INTEGER REFERENCES files (fileID),
-			speakerID INTEGER REFERENCES speakers (speakerID),
-			phraseID INTEGER REFERENCES phrases (phraseID),
-			count INTEGER)`,
-		`CREATE INDEX phraseCountsSpeakerID ON phraseCounts (speakerID)`,
-		`CREATE INDEX phraseCountsPhraseID ON phraseCounts (phraseID)`,
-		`CREATE TABLE prefaceCounts (
-			fileID INTEGER REFERENCES files (fileID),
-			speakerID INTEGER REFERENCES speakers (speakerID),
-			prefaceID INTEGER REFERENCES prefaces (prefaceID),
-			count INTEGER)`,
-		`CREATE INDEX prefaceCountsSpeakerID ON prefaceCounts (speakerID)`,
-		`CREATE INDEX prefaceCountsPrefaceID ON prefaceCounts (prefaceID)`,
-	} {
-		if _, err := tx.Exec(statement); err != nil {
-			return err
-		}
-	}
-
-	return tx.Commit()
+	return Schema.Apply(db.db)
 }
 
 func parseDate(dateString sql.NullString) time.Time {
@@ -103,51 +169,50 @@ func parseTimestamp(timestampString sql.NullString) time.Time {
 	return t
 }
 
-func (db *phraseDB) lastFetchTimestamp() (time.Time, error) {
-	rows, err := db.db.Query("SELECT MIN(phrases.lastFetchTimestamp),  MIN(prefaces.lastFetchTimestamp) FROM phrases, prefaces")
+func (db *phraseDB) fetchCursor() (time.Time, int64, error) {
+	rows, err := db.db.Query(`SELECT lastFetchTimestamp, lastFileID FROM (
+			SELECT lastFetchTimestamp, lastFileID FROM phrases
+			UNION ALL
+			SELECT lastFetchTimestamp, lastFileID FROM prefaces)
+		ORDER BY lastFetchTimestamp ASC, lastFileID ASC LIMIT 1`)
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, 0, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var phrasesFetchTimestamp sql.NullString
-		var prefacesFetchTimestamp sql.NullString
-		if err := rows.Scan(&phrasesFetchTimestamp, &prefacesFetchTimestamp); err != nil {
-			return time.Time{}, err
-		}
-		ts1 := parseTimestamp(phrasesFetchTimestamp)
-		ts2 := parseTimestamp(prefacesFetchTimestamp)
-		if ts1.Before(ts2) {
-			return ts1, nil
-		} else {
-			return ts2, nil
+		var fetchTimestamp sql.NullString
+		var fileID int64
+		if err := rows.Scan(&fetchTimestamp, &fileID); err != nil {
+			return time.Time{}, 0, err
 		}
+		return parseTimestamp(fetchTimestamp), fileID, nil
 	}
-	return time.Time{}, nil
+	return time.Time{}, 0, rows.Err()
 }
 
-func (db *phraseDB) setFetchTimestamp(lastFetchTimestamp time.Time) error {
+func (db *phraseDB) setFetchCursor(lastFetchTimestamp time.Time, lastFileID int64) error {
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("UPDATE phrases SET lastFetchTimestamp = ? WHERE lastFetchTimestamp < ?", lastFetchTimestamp.Format(time.DateTime), lastFetchTimestamp.Format(time.DateTime)); err != nil {
+	ts := lastFetchTimestamp.Format(time.DateTime)
+	if _, err := tx.Exec("UPDATE phrases SET lastFetchTimestamp = ?, lastFileID = ? WHERE (lastFetchTimestamp, lastFileID) < (?, ?)", ts, lastFileID, ts, lastFileID); err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec("UPDATE prefaces SET lastFetchTimestamp = ? WHERE lastFetchTimestamp < ?", lastFetchTimestamp.Format(time.DateTime), lastFetchTimestamp.Format(time.DateTime)); err != nil {
+	if _, err := tx.Exec("UPDATE prefaces SET lastFetchTimestamp = ?, lastFileID = ? WHERE (lastFetchTimestamp, lastFileID) < (?, ?)", ts, lastFileID, ts, lastFileID); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func (db *phraseDB) unfetchedPhrasesPrefaces(fetchTimestamp time.Time) (map[string]int64, map[string]int64, error) {
+func (db *phraseDB) unfetchedPhrasesPrefaces(fetchTimestamp time.Time, fileID int64) (map[string]int64, map[string]int64, error) {
 	phrases := map[string]int64{}
-	rows, err := db.db.Query("SELECT phraseID, phrase FROM phrases WHERE lastFetchTimestamp < ?", fetchTimestamp.Format(time.DateTime))
+	rows, err := db.db.Query("SELECT phraseID, phrase FROM phrases WHERE (lastFetchTimestamp, lastFileID) < (?, ?)", fetchTimestamp.Format(time.DateTime), fileID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -163,7 +228,7 @@ func (db *phraseDB) unfetchedPhrasesPrefaces(fetchTimestamp time.Time) (map[stri
 	}
 
 	prefaces := map[string]int64{}
-	rows, err = db.db.Query("SELECT prefaceID, preface FROM prefaces WHERE lastFetchTimestamp < ?", fetchTimestamp.Format(time.DateTime))
+	rows, err = db.db.Query("SELECT prefaceID, preface FROM prefaces WHERE (lastFetchTimestamp, lastFileID) < (?, ?)", fetchTimestamp.Format(time.DateTime), fileID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -218,43 +283,68 @@ func (db *phraseDB) phrasesPrefaces() (map[string]int64, map[string]int64, error
 }
 
 func (db *phraseDB) addPhrase(phrase string) error {
+	p, err := compilePattern(phrase)
+	if err != nil {
+		return err
+	}
+
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("INSERT INTO phrases (phrase, lastFetchTimestamp) VALUES (?, '1970-01-01 00:00:00')", phrase); err != nil {
+	if _, err := tx.Exec("INSERT INTO phrases (phrase, kind, lastFetchTimestamp) VALUES (?, ?, '1970-01-01 00:00:00')", phrase, p.kind); err != nil {
 		return err
 	}
 	return tx.Commit()
 }
 
 func (db *phraseDB) addPreface(preface string) error {
+	p, err := compilePattern(preface)
+	if err != nil {
+		return err
+	}
+
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("INSERT INTO prefaces (preface, lastFetchTimestamp) VALUES (?, '1970-01-01 00:00:00')", preface); err != nil {
+	if _, err := tx.Exec("INSERT INTO prefaces (preface, kind, lastFetchTimestamp) VALUES (?, ?, '1970-01-01 00:00:00')", preface, p.kind); err != nil {
 		return err
 	}
 	return tx.Commit()
 }
 
-func (db *phraseDB) addCounts(fileID int64, date time.Time, phrases, prefaces map[string]int64, phraseCounts map[[2]string]int, prefaceCounts map[[2]string]int) error {
+func (db *phraseDB) addCounts(fileID int64, date time.Time, wordCount int, normalization, tokenizer string, roles map[string]string, registryIDs map[string]int64, phrases, prefaces map[string]int64, phraseCounts map[[2]string]int, prefaceCounts map[[2]string]int) error {
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("INSERT OR IGNORE INTO files (fileID, date) VALUES (?,?)", fileID, date.Format(time.DateOnly)); err != nil {
+	if _, err := tx.Exec("INSERT INTO files (fileID, date, wordCount) VALUES (?,?,?) ON CONFLICT (fileID) DO UPDATE SET wordCount = excluded.wordCount", fileID, date.Format(time.DateOnly), wordCount); err != nil {
 		return err
 	}
 
 	speakerIDs := map[string]int64{}
+	for speaker, role := range roles {
+		speakerID, err := db.getSpeakerID(tx, speaker)
+		if err != nil {
+			return err
+		}
+		if registryID, ok := registryIDs[speaker]; ok {
+			if _, err := tx.Exec("UPDATE speakers SET registryID = ? WHERE speakerID = ?", registryID, speakerID); err != nil {
+				return err
+			}
+		}
+		speakerIDs[speaker] = speakerID
+		if _, err := tx.Exec("INSERT OR REPLACE INTO fileSpeakers (fileID, speakerID, role) VALUES (?,?,?)", fileID, speakerID, role); err != nil {
+			return err
+		}
+	}
 	for item := range phraseCounts {
 		if _, ok := speakerIDs[item[0]]; ok {
 			continue
@@ -281,8 +371,8 @@ func (db *phraseDB) addCounts(fileID int64, date time.Time, phrases, prefaces ma
 		if !ok || count <= 0 {
 			continue
 		}
-		if _, err := tx.Exec("INSERT INTO phraseCounts (fileID, speakerID, phraseID, count) VALUES (?,?,?,?)", fileID, speakerIDs[item[0]], phraseID, count); err != nil {
-			return nil
+		if _, err := tx.Exec("INSERT INTO phraseCounts (fileID, speakerID, phraseID, count, normalization, tokenizer) VALUES (?,?,?,?,?,?)", fileID, speakerIDs[item[0]], phraseID, count, normalization, tokenizer); err != nil {
+			return err
 		}
 	}
 
@@ -291,8 +381,8 @@ func (db *phraseDB) addCounts(fileID int64, date time.Time, phrases, prefaces ma
 		if !ok || count <= 0 {
 			continue
 		}
-		if _, err := tx.Exec("INSERT INTO prefaceCounts (fileID, speakerID, prefaceID, count) VALUES (?,?,?,?)", fileID, speakerIDs[item[0]], prefaceID, count); err != nil {
-			return nil
+		if _, err := tx.Exec("INSERT INTO prefaceCounts (fileID, speakerID, prefaceID, count, normalization, tokenizer) VALUES (?,?,?,?,?,?)", fileID, speakerIDs[item[0]], prefaceID, count, normalization, tokenizer); err != nil {
+			return err
 		}
 	}
 
@@ -325,3 +415,250 @@ func (db *phraseDB) getSpeakerID(tx *sql.Tx, speaker string) (int64, error) {
 	}
 	return 0, fmt.Errorf("Failed to get speakerID for %s", speaker)
 }
+
+func (db *phraseDB) syncSpeakers() error {
+	rows, err := db.db.Query("SELECT name, registryID FROM speakers WHERE name IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	current := map[string]int64{}
+	for rows.Next() {
+		var name string
+		var registryID sql.NullInt64
+		if err := rows.Scan(&name, &registryID); err != nil {
+			return err
+		}
+		names = append(names, name)
+		current[name] = registryID.Int64
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	registryIDs, err := speakers.Resolve(names)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for name, registryID := range registryIDs {
+		if current[name] == registryID {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE speakers SET registryID = ? WHERE name = ?", registryID, name); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+type phraseCount struct {
+	period string
+	kind   string
+	phrase string
+	count  int
+}
+
+type countFilter struct {
+	registryID    int64
+	feedID        int64
+	normalization string
+	tokenizer     string
+	from          time.Time
+	to            time.Time
+}
+
+func (filter countFilter) conditions() ([]string, []any) {
+	conditions := []string{}
+	args := []any{}
+	if filter.registryID != 0 {
+		conditions = append(conditions, "speakers.registryID = ?")
+		args = append(args, filter.registryID)
+	}
+	if filter.feedID != 0 {
+		conditions = append(conditions, "counts.fileID IN (SELECT fileID FROM fetcher.files WHERE feedID = ?)")
+		args = append(args, filter.feedID)
+	}
+	if filter.normalization != "" {
+		conditions = append(conditions, "counts.normalization = ?")
+		args = append(args, filter.normalization)
+	}
+	if filter.tokenizer != "" {
+		conditions = append(conditions, "counts.tokenizer = ?")
+		args = append(args, filter.tokenizer)
+	}
+	if !filter.from.IsZero() {
+		conditions = append(conditions, "files.date >= ?")
+		args = append(args, filter.from.Format(time.DateOnly))
+	}
+	if !filter.to.IsZero() {
+		conditions = append(conditions, "files.date <= ?")
+		args = append(args, filter.to.Format(time.DateOnly))
+	}
+	return conditions, args
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+func (db *phraseDB) periodCounts(period string, withWordCount bool, filter countFilter) ([]phraseCount, error) {
+	periodExpr, err := report.PeriodExpression(period, "files.date")
+	if err != nil {
+		return nil, err
+	}
+	conditions, args := filter.conditions()
+	if withWordCount {
+		conditions = append(conditions, "files.wordCount IS NOT NULL")
+	}
+	where := whereClause(conditions)
+
+	rows, err := db.db.Query(`SELECT `+periodExpr+` AS period, 'phrase', phrases.phrase, SUM(counts.count)
+			FROM phraseCounts counts
+			JOIN files ON files.fileID = counts.fileID
+			JOIN phrases ON phrases.phraseID = counts.phraseID
+			JOIN speakers ON speakers.speakerID = counts.speakerID
+			`+where+`
+			GROUP BY period, counts.phraseID
+		UNION ALL
+		SELECT `+periodExpr+` AS period, 'preface', prefaces.preface, SUM(counts.count)
+			FROM prefaceCounts counts
+			JOIN files ON files.fileID = counts.fileID
+			JOIN prefaces ON prefaces.prefaceID = counts.prefaceID
+			JOIN speakers ON speakers.speakerID = counts.speakerID
+			`+where+`
+			GROUP BY period, counts.prefaceID
+		ORDER BY 1, 2, 3`, append(args, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []phraseCount{}
+	for rows.Next() {
+		count := phraseCount{}
+		if err := rows.Scan(&count.period, &count.kind, &count.phrase, &count.count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+type periodTotal struct {
+	period    string
+	files     int
+	wordFiles int
+	words     int
+}
+
+func (db *phraseDB) periodTotals(period string, filter countFilter) ([]periodTotal, error) {
+	periodExpr, err := report.PeriodExpression(period, "files.date")
+	if err != nil {
+		return nil, err
+	}
+	conditions := []string{}
+	args := []any{}
+	if filter.registryID != 0 {
+		conditions = append(conditions, `files.fileID IN (SELECT fileSpeakers.fileID
+			FROM fileSpeakers
+			JOIN speakers ON speakers.speakerID = fileSpeakers.speakerID
+			WHERE speakers.registryID = ?)`)
+		args = append(args, filter.registryID)
+	}
+	if filter.feedID != 0 {
+		conditions = append(conditions, "files.fileID IN (SELECT fileID FROM fetcher.files WHERE feedID = ?)")
+		args = append(args, filter.feedID)
+	}
+	where := whereClause(conditions)
+
+	rows, err := db.db.Query(`SELECT `+periodExpr+` AS period, COUNT(*), COUNT(files.wordCount), SUM(files.wordCount)
+		FROM files
+		`+where+`
+		GROUP BY period
+		ORDER BY period`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []periodTotal{}
+	for rows.Next() {
+		total := periodTotal{}
+		var words sql.NullInt64
+		if err := rows.Scan(&total.period, &total.files, &total.wordFiles, &words); err != nil {
+			return nil, err
+		}
+		total.words = int(words.Int64)
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
+
+func (db *phraseDB) exportCounts(filter countFilter, write func(values ...any) error) error {
+	conditions, args := filter.conditions()
+	where := whereClause(conditions)
+
+	attached, err := attach.Attached(db.db, "fetcher")
+	if err != nil {
+		return err
+	}
+	feedColumn, feedJoin := "NULL", ""
+	if attached {
+		feedColumn, feedJoin = "feedFiles.feedID", "LEFT JOIN fetcher.files feedFiles ON feedFiles.fileID = counts.fileID"
+	}
+
+	rows, err := db.db.Query(`SELECT files.date, counts.fileID, `+feedColumn+`, speakers.name, 'phrase', phrases.phrase, SUM(counts.count)
+			FROM phraseCounts counts
+			JOIN files ON files.fileID = counts.fileID
+			JOIN phrases ON phrases.phraseID = counts.phraseID
+			JOIN speakers ON speakers.speakerID = counts.speakerID
+			`+feedJoin+`
+			`+where+`
+			GROUP BY counts.fileID, counts.speakerID, counts.phraseID
+		UNION ALL
+		SELECT files.date, counts.fileID, `+feedColumn+`, speakers.name, 'preface', prefaces.preface, SUM(counts.count)
+			FROM prefaceCounts counts
+			JOIN files ON files.fileID = counts.fileID
+			JOIN prefaces ON prefaces.prefaceID = counts.prefaceID
+			JOIN speakers ON speakers.speakerID = counts.speakerID
+			`+feedJoin+`
+			`+where+`
+			GROUP BY counts.fileID, counts.speakerID, counts.prefaceID
+		ORDER BY 1, 2, 4, 5, 6`, append(args, args...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date sql.NullString
+		var fileID, count int64
+		var feedID sql.NullInt64
+		var speaker, kind, phrase string
+		if err := rows.Scan(&date, &fileID, &feedID, &speaker, &kind, &phrase, &count); err != nil {
+			return err
+		}
+		var feed any
+		if feedID.Valid {
+			feed = feedID.Int64
+		}
+		if err := write(parseDate(date), fileID, feed, speaker, kind, phrase, count); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
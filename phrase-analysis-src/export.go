@@ -0,0 +1,85 @@
+package phraseAnalysis
+
+import (
+	"fmt"
+	"time"
+
+	attach "language-analysis/attach-src"
+	"language-analysis/config"
+	export "language-analysis/export-src"
+	speakers "language-analysis/speakers-src"
+)
+
+var exportColumns = []export.Column{
+	{Name: "date", Kind: export.Date},
+	{Name: "fileID", Kind: export.Int64},
+	{Name: "feed", Kind: export.Int64, Nullable: true},
+	{Name: "speaker", Kind: export.String},
+	{Name: "kind", Kind: export.String},
+	{Name: "phrase", Kind: export.String},
+	{Name: "count", Kind: export.Int64},
+}
+
+func ExportCommand() error {
+	tokenizer, normalization, err := analyzer.ReportFilters(config.String("report-tokenizer"), config.String("report-normalization"))
+	if err != nil {
+		return config.Usagef("Invalid report filter: %v", err)
+	}
+	filter := countFilter{
+		feedID:        int64(config.Int("report-feed")),
+		normalization: normalization,
+		tokenizer:     tokenizer,
+	}
+	if s := config.String("from"); s != "" {
+		if filter.from, err = time.Parse(time.DateOnly, s); err != nil {
+			return fmt.Errorf("Invalid from date: %v", err)
+		}
+	}
+	if s := config.String("to"); s != "" {
+		if filter.to, err = time.Parse(time.DateOnly, s); err != nil {
+			return fmt.Errorf("Invalid to date: %v", err)
+		}
+	}
+	if speaker := config.String("report-speaker"); speaker != "" {
+		s, err := speakers.Find(speaker)
+		if err != nil {
+			return err
+		}
+		filter.registryID = s.ID()
+	}
+
+	db, err := openPhraseDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if filter.feedID != 0 {
+		if err := attach.Require(db.db, "fetcher"); err != nil {
+			return err
+		}
+	}
+
+	if filter.registryID != 0 {
+		if err := db.syncSpeakers(); err != nil {
+			return err
+		}
+	}
+
+	out, err := export.Create(config.String("export-output"))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w, err := export.NewWriter(config.String("export-format"), out, exportColumns)
+	if err != nil {
+		return err
+	}
+	if err := db.exportCounts(filter, w.Write); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,170 @@
+package phraseAnalysis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	kindLiteral = "literal"
+	kindGlob    = "glob"
+	kindRegex   = "regex"
+)
+
+type pattern struct {
+	phrase string
+	kind   string
+	words  []string
+	rx     *regexp.Regexp
+}
+
+func phraseKind(phrase string) (string, string) {
+	if body, ok := strings.CutPrefix(phrase, "re:"); ok {
+		return kindRegex, body
+	}
+	if body, ok := strings.CutPrefix(phrase, "glob:"); ok {
+		return kindGlob, body
+	}
+	return kindLiteral, phrase
+}
+
+func compilePattern(phrase string) (pattern, error) {
+	kind, body := phraseKind(phrase)
+	p := pattern{phrase: phrase, kind: kind}
+	switch kind {
+	case kindRegex:
+		rx, err := regexp.Compile(body)
+		if err != nil {
+			return pattern{}, fmt.Errorf("Invalid phrase %s: %v", phrase, err)
+		}
+		p.rx = rx
+	case kindGlob:
+		words := []string{}
+		for _, word := range strings.Fields(strings.ToLower(body)) {
+			switch word {
+			case "*":
+				words = append(words, `[^ ]+`)
+			case "**":
+				words = append(words, `[^ ]+(?: [^ ]+)*`)
+			default:
+				if strings.Contains(word, "*") {
+					words = append(words, strings.ReplaceAll(regexp.QuoteMeta(word), `\*`, `[^ ]*`))
+					continue
+				}
+				for _, sentence := range analyzer.Sentences(word) {
+					for _, w := range sentence {
+						words = append(words, regexp.QuoteMeta(w))
+					}
+				}
+			}
+		}
+		if len(words) == 0 {
+			return pattern{}, fmt.Errorf("Empty phrase %s", phrase)
+		}
+		p.rx = regexp.MustCompile(` ` + strings.Join(words, " ") + ` `)
+	default:
+		for _, sentence := range analyzer.Sentences(body) {
+			p.words = append(p.words, sentence...)
+		}
+		if len(p.words) == 0 {
+			return pattern{}, fmt.Errorf("Empty phrase %s", phrase)
+		}
+	}
+	return p, nil
+}
+
+// compilePatterns compiles phrases, reusing and adding to the patterns
+// already in compiled, so a collect run compiles each phrase once.
+func compilePatterns(phrases map[string]int64, compiled map[string]pattern) ([]pattern, error) {
+	patterns := []pattern{}
+	for phrase := range phrases {
+		p, ok := compiled[phrase]
+		if !ok {
+			var err error
+			if p, err = compilePattern(phrase); err != nil {
+				return nil, err
+			}
+			compiled[phrase] = p
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func (p pattern) count(sentence []string, atStart bool) int {
+	return len(p.matches(sentence, atStart))
+}
+
+func (p pattern) matches(sentence []string, atStart bool) [][2]int {
+	matches := [][2]int{}
+	switch p.kind {
+	case kindRegex:
+		text := strings.Join(sentence, " ")
+		if atStart {
+			if loc := p.rx.FindStringIndex(text); loc != nil && loc[0] == 0 {
+				matches = append(matches, wordRange(sentence, loc[0], loc[1]))
+			}
+			return matches
+		}
+		for _, loc := range p.rx.FindAllStringIndex(text, -1) {
+			matches = append(matches, wordRange(sentence, loc[0], loc[1]))
+		}
+	case kindGlob:
+		text := " " + strings.Join(sentence, " ") + " "
+		for offset := 0; offset < len(text); {
+			loc := p.rx.FindStringIndex(text[offset:])
+			if loc == nil || (atStart && offset+loc[0] != 0) {
+				break
+			}
+			matches = append(matches, wordRange(sentence, offset+loc[0], offset+loc[1]-2))
+			if atStart {
+				break
+			}
+			// Look for the next match from the following word, so
+			// overlapping matches count as they do for literal phrases.
+			offset += loc[0] + 1
+			offset += strings.IndexByte(text[offset:], ' ')
+		}
+	default:
+		for i := 0; i+len(p.words) <= len(sentence); i++ {
+			if p.matchesAt(sentence, i) {
+				matches = append(matches, [2]int{i, i + len(p.words)})
+			}
+			if atStart {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func wordRange(sentence []string, start, end int) [2]int {
+	r := [2]int{-1, 0}
+	offset := 0
+	for i, word := range sentence {
+		if r[0] < 0 && start < offset+len(word)+1 {
+			r[0] = i
+		}
+		if end > offset {
+			r[1] = i + 1
+		}
+		offset += len(word) + 1
+	}
+	if r[0] < 0 {
+		r[0] = len(sentence)
+	}
+	if r[1] < r[0] {
+		r[1] = r[0]
+	}
+	return r
+}
+
+func (p pattern) matchesAt(sentence []string, i int) bool {
+	for j, word := range p.words {
+		if sentence[i+j] != word {
+			return false
+		}
+	}
+	return true
+}
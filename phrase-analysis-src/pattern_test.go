@@ -0,0 +1,102 @@
+package phraseAnalysis
+
+import (
+	"fmt"
+	"testing"
+
+	scraper "language-analysis/scraper-src"
+)
+
+func TestPatternNormalization(t *testing.T) {
+	defer func(saved scraper.Analyzer) { analyzer = saved }(analyzer)
+	tests := []struct {
+		normalization string
+		phrase        string
+		text          string
+		want          int
+	}{
+		{"raw", "glob:thanks for *", "Thanks for having me.", 1},
+		{"lemma", "glob:thanks for *", "Thanks for having me.", 1},
+		{"lemma", "glob:thanks for *", "Thank you for having me.", 0},
+		{"lemma", "thanks for having", "Thanks for having me.", 1},
+		{"normalized", "glob:you're *", "Youre welcome.", 1},
+		{"normalized", "glob:you're welcome", "You are welcome.", 1},
+		{"lemma", "glob:definite*", "Definitely.", 1},
+		{"lemma", "re:^thank for", "Thanks for having me.", 1},
+		{"lemma", "re:^thanks for", "Thanks for having me.", 0},
+	}
+	for _, test := range tests {
+		a, err := scraper.MakeAnalyzer("", test.normalization, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		analyzer = a
+		p, err := compilePattern(test.phrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := 0
+		for _, sentence := range analyzer.Sentences(test.text) {
+			got += p.count(sentence, false)
+		}
+		if got != test.want {
+			t.Errorf("%s %q in %q = %d, want %d", test.normalization, test.phrase, test.text, got, test.want)
+		}
+	}
+}
+
+func TestPatternMatches(t *testing.T) {
+	defer func(saved scraper.Analyzer) { analyzer = saved }(analyzer)
+	a, err := scraper.MakeAnalyzer("", "raw", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyzer = a
+	tests := []struct {
+		phrase  string
+		text    string
+		atStart bool
+		want    [][2]int
+	}{
+		{"thank you so much for having me", "Well, thank you so much for having me on.", false, [][2]int{{1, 8}}},
+		{"thank you so much for having me", "Thank you so much for having us.", false, [][2]int{}},
+		{"very very", "It was very very very good.", false, [][2]int{{2, 4}, {3, 5}}},
+		{"glob:you bet", "You bet, you bet.", false, [][2]int{{0, 2}, {2, 4}}},
+		{"glob:very *", "It was very very good.", false, [][2]int{{2, 4}, {3, 5}}},
+		{"glob:* bet", "You bet you bet.", false, [][2]int{{0, 2}, {2, 4}}},
+		{"glob:thank ** much", "Thank you very much.", false, [][2]int{{0, 4}}},
+		{"glob:thank ** much", "Thank much.", false, [][2]int{}},
+		{"glob:a ** of *", "A perfect storm of trouble.", false, [][2]int{{0, 5}}},
+		{"look", "Look, look.", true, [][2]int{{0, 1}}},
+		{"look", "Well, look.", true, [][2]int{}},
+		{"glob:absolute*", "Absolutely, absolutely.", true, [][2]int{{0, 1}}},
+		{"glob:absolute*", "I absolutely agree.", true, [][2]int{}},
+		{"re:look", "Look, look.", true, [][2]int{{0, 1}}},
+		{"re:look", "Well, look.", true, [][2]int{}},
+	}
+	for _, test := range tests {
+		p, err := compilePattern(test.phrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := p.matches(analyzer.Sentences(test.text)[0], test.atStart)
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%q in %q (at start %v) = %v, want %v", test.phrase, test.text, test.atStart, got, test.want)
+		}
+	}
+}
+
+func TestCompilePatternsOnce(t *testing.T) {
+	compiled := map[string]pattern{}
+	if _, err := compilePatterns(map[string]int64{"bucket list": 1, "glob:definite*": 2}, compiled); err != nil {
+		t.Fatal(err)
+	}
+	compiled["bucket list"] = pattern{phrase: "cached"}
+	patterns, err := compilePatterns(map[string]int64{"bucket list": 1}, compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compiled) != 2 || len(patterns) != 1 || patterns[0].phrase != "cached" {
+		t.Errorf("compilePatterns recompiled %v", patterns)
+	}
+}
@@ -6,8 +6,6 @@ import (
 	scraper "language-analysis/scraper-src"
 )
 
-const maxWords = 5
-
 func PhrasesPrefaces() (map[string]int64, map[string]int64, error) {
 	db, err := openPhraseDB()
 	if err != nil {
@@ -15,7 +13,7 @@ func PhrasesPrefaces() (map[string]int64, map[string]int64, error) {
 	}
 	defer db.Close()
 
-	return db.unfetchedPhrasesPrefaces(time.Now().UTC())
+	return db.unfetchedPhrasesPrefaces(time.Now().UTC(), 0)
 }
 
 func AddPhrase(phrase string) error {
@@ -38,35 +36,41 @@ func AddPreface(preface string) error {
 	return db.addPreface(preface)
 }
 
-func CountPhrases(transcript []scraper.Transcript, phrases, prefaces map[string]int64) (map[[2]string]int, map[[2]string]int) {
+func CountPhrases(transcript []scraper.Transcript, phrases, prefaces map[string]int64, compiled map[string]pattern) (map[[2]string]int, map[[2]string]int, error) {
+	phrasePatterns, err := compilePatterns(phrases, compiled)
+	if err != nil {
+		return nil, nil, err
+	}
+	prefacePatterns, err := compilePatterns(prefaces, compiled)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	phraseCounts := map[[2]string]int{}
 	prefaceCounts := map[[2]string]int{}
-
 	for _, ts := range transcript {
-		phraser := scraper.MakePhraser(maxWords, ts.Text)
-		preface := true
-		for phrase := phraser.Next(); phrase != nil; phrase = phraser.Next() {
-			collect(phrases, ts.Name, phrase, phraseCounts)
-			if preface {
-				collect(prefaces, ts.Name, phrase, prefaceCounts)
-				preface = false
+		for i, sentence := range analyzer.Sentences(ts.Text) {
+			collect(phrasePatterns, ts.Name, sentence, false, phraseCounts)
+			if i == 0 {
+				collect(prefacePatterns, ts.Name, sentence, true, prefaceCounts)
 			}
 		}
 	}
-	return phraseCounts, prefaceCounts
+	return phraseCounts, prefaceCounts, nil
 }
 
-func collect(phrases map[string]int64, speaker string, phrase []string, phraseCounts map[[2]string]int) {
-	p := phrase[0]
-	if _, ok := phrases[p]; ok {
-		phraseCounts[[2]string{speaker, p}]++
+func CountWords(transcript []scraper.Transcript) int {
+	count := 0
+	for _, ts := range transcript {
+		count += analyzer.CountWords(ts.Text)
 	}
-	for _, w := range phrase[1:] {
-		if w != "" {
-			p += " " + w
-			if _, ok := phrases[p]; ok {
-				phraseCounts[[2]string{speaker, p}]++
-			}
+	return count
+}
+
+func collect(patterns []pattern, speaker string, sentence []string, atStart bool, phraseCounts map[[2]string]int) {
+	for _, p := range patterns {
+		if count := p.count(sentence, atStart); count > 0 {
+			phraseCounts[[2]string{speaker, p.phrase}] += count
 		}
 	}
 }
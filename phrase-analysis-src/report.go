@@ -0,0 +1,164 @@
+package phraseAnalysis
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	attach "language-analysis/attach-src"
+	"language-analysis/config"
+	speakers "language-analysis/speakers-src"
+)
+
+type ratio struct {
+	name        string
+	numerator   string
+	denominator string
+}
+
+func ReportCommand() error {
+	period := config.String("report-period")
+	normalize := config.String("report-normalize")
+	format := config.String("report-format")
+
+	ratios := []ratio{}
+	if r := config.String("report-ratio"); r != "" {
+		for _, item := range strings.Split(r, ",") {
+			numerator, denominator, ok := strings.Cut(item, "/")
+			if !ok {
+				return config.Usagef("Invalid report ratio: %s", item)
+			}
+			ratios = append(ratios, ratio{
+				name:        item,
+				numerator:   strings.TrimSpace(numerator),
+				denominator: strings.TrimSpace(denominator),
+			})
+		}
+	}
+
+	tokenizer, normalization, err := analyzer.ReportFilters(config.String("report-tokenizer"), config.String("report-normalization"))
+	if err != nil {
+		return config.Usagef("Invalid report filter: %v", err)
+	}
+	filter := countFilter{
+		feedID:        int64(config.Int("report-feed")),
+		normalization: normalization,
+		tokenizer:     tokenizer,
+	}
+	if speaker := config.String("report-speaker"); speaker != "" {
+		s, err := speakers.Find(speaker)
+		if err != nil {
+			return err
+		}
+		filter.registryID = s.ID()
+	}
+
+	db, err := openPhraseDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if filter.feedID != 0 {
+		if err := attach.Require(db.db, "fetcher"); err != nil {
+			return err
+		}
+	}
+
+	if filter.registryID != 0 {
+		if err := db.syncSpeakers(); err != nil {
+			return err
+		}
+	}
+
+	counts, err := db.periodCounts(period, normalize == "10k", filter)
+	if err != nil {
+		return err
+	}
+	totals, err := db.periodTotals(period, filter)
+	if err != nil {
+		return err
+	}
+
+	periodTotals := map[string]periodTotal{}
+	for _, total := range totals {
+		periodTotals[total.period] = total
+	}
+	value := func(period string, count int) (float64, bool) {
+		total := periodTotals[period]
+		switch normalize {
+		case "transcript":
+			if total.files == 0 {
+				return 0, false
+			}
+			return float64(count) / float64(total.files), true
+		case "10k":
+			if total.words == 0 {
+				return 0, false
+			}
+			return 10000 * float64(count) / float64(total.words), true
+		default:
+			return float64(count), true
+		}
+	}
+
+	phraseCounts := map[[3]string]int{}
+	for _, count := range counts {
+		phraseCounts[[3]string{count.period, count.kind, count.phrase}] = count.count
+	}
+	ratioCount := func(period, name string) int {
+		if preface, ok := strings.CutPrefix(name, "preface:"); ok {
+			return phraseCounts[[3]string{period, "preface", preface}]
+		}
+		return phraseCounts[[3]string{period, "phrase", name}]
+	}
+
+	var writeRow func(...string)
+	var flush func() error
+	if format == "csv" {
+		out := csv.NewWriter(os.Stdout)
+		writeRow = func(fields ...string) {
+			out.Write(fields)
+		}
+		flush = func() error {
+			out.Flush()
+			return out.Error()
+		}
+	} else {
+		out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		writeRow = func(fields ...string) {
+			fmt.Fprintf(out, "%s\t\n", strings.Join(fields, "\t"))
+		}
+		flush = out.Flush
+	}
+
+	writeRow("period", "kind", "phrase", "count", "transcripts", "words", normalize)
+	for _, count := range counts {
+		total := periodTotals[count.period]
+		v, ok := value(count.period, count.count)
+		writeRow(count.period, count.kind, count.phrase, fmt.Sprintf("%d", count.count), fmt.Sprintf("%d", total.files), fmt.Sprintf("%d", total.words), formatValue(v, ok))
+	}
+
+	if len(ratios) > 0 {
+		if format == "table" {
+			writeRow()
+		}
+		for _, total := range totals {
+			for _, r := range ratios {
+				numerator, ok1 := value(total.period, ratioCount(total.period, r.numerator))
+				denominator, ok2 := value(total.period, ratioCount(total.period, r.denominator))
+				writeRow(total.period, "ratio", r.name, "", fmt.Sprintf("%d", total.files), fmt.Sprintf("%d", total.words), formatValue(numerator/denominator, ok1 && ok2 && denominator != 0))
+			}
+		}
+	}
+	return flush()
+}
+
+func formatValue(v float64, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%.4f", v)
+}
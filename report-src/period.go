@@ -0,0 +1,20 @@
+package report
+
+import (
+	"fmt"
+)
+
+func PeriodExpression(period, date string) (string, error) {
+	switch period {
+	case "day":
+		return "strftime('%Y-%m-%d', " + date + ")", nil
+	case "week":
+		return "date(" + date + ", '-6 days', 'weekday 1')", nil
+	case "month":
+		return "strftime('%Y-%m', " + date + ")", nil
+	case "year":
+		return "strftime('%Y', " + date + ")", nil
+	default:
+		return "", fmt.Errorf("Unknown period: %s", period)
+	}
+}
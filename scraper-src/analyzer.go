@@ -0,0 +1,95 @@
+package scraper
+
+import "strings"
+
+type Analyzer struct {
+	tokenizer  string
+	tokenize   func(string) []Token
+	normalizer *Normalizer
+}
+
+func MakeAnalyzer(tokenizer, normalization string, variants map[string]string) (Analyzer, error) {
+	t, err := Tokenizer(tokenizer)
+	if err != nil {
+		return Analyzer{}, err
+	}
+	n, err := MakeNormalizer(normalization, variants)
+	if err != nil {
+		return Analyzer{}, err
+	}
+	if tokenizer == "" {
+		tokenizer = "sentences"
+	}
+	return Analyzer{tokenizer: tokenizer, tokenize: t, normalizer: n}, nil
+}
+
+func (a Analyzer) TokenizerName() string {
+	if a.tokenizer == "" {
+		return "sentences"
+	}
+	return a.tokenizer
+}
+
+func (a Analyzer) NormalizationName() string {
+	return a.normalizer.Name()
+}
+
+func (a Analyzer) Normalizer() *Normalizer {
+	return a.normalizer
+}
+
+func (a Analyzer) Tokenize(text string) []Token {
+	if a.tokenize == nil {
+		return Tokenize(text)
+	}
+	return a.tokenize(text)
+}
+
+func (a Analyzer) Tokens(text string) []Token {
+	return a.normalizer.Tokens(a.Tokenize(text))
+}
+
+func (a Analyzer) Sentences(text string) [][]string {
+	return Sentences(a.Tokens(text))
+}
+
+// CountWords counts the words the analyzer finds in text, after
+// normalization, so rates per word match the words phrases are
+// counted in.
+func (a Analyzer) CountWords(text string) int {
+	count := 0
+	for _, token := range a.Tokens(text) {
+		if strings.TrimFunc(token.Text, trimFunc) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// ReportFilters returns the tokenizer and normalization to restrict a
+// report to, the analyzer's own unless given, or "" for "all".
+func (a Analyzer) ReportFilters(tokenizer, normalization string) (string, string, error) {
+	switch tokenizer {
+	case "":
+		tokenizer = a.TokenizerName()
+	case "all":
+		tokenizer = ""
+	default:
+		if _, err := Tokenizer(tokenizer); err != nil {
+			return "", "", err
+		}
+	}
+	switch normalization {
+	case "":
+		normalization = a.NormalizationName()
+	case "all":
+		normalization = ""
+	default:
+		n, err := MakeNormalizer(normalization, nil)
+		if err != nil {
+			return "", "", err
+		}
+		normalization = n.Name()
+	}
+	return tokenizer, normalization, nil
+}
@@ -0,0 +1,52 @@
+package scraper
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+var quoteReplacer = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'",
+	"“", "\"", "”", "\"", "„", "\"", "‟", "\"", "″", "\"",
+	"«", "\"", "»", "\"",
+)
+
+func htmlText(data []byte) string {
+	text := strings.Builder{}
+	skip := ""
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return normalizeText(text.String())
+		case html.TextToken:
+			if skip == "" {
+				text.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style":
+				skip = string(name)
+			case "br", "p", "div", "li", "tr", "td":
+				text.WriteByte(' ')
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case skip:
+				skip = ""
+			case "p", "div", "li", "td":
+				text.WriteByte(' ')
+			}
+		}
+	}
+}
+
+func normalizeText(text string) string {
+	text = quoteReplacer.Replace(text)
+	return strings.Join(strings.FieldsFunc(text, unicode.IsSpace), " ")
+}
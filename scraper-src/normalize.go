@@ -0,0 +1,303 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	NormalizeRaw          = "raw"
+	NormalizeVariants     = "variants"
+	NormalizeContractions = "contractions"
+	NormalizeLemma        = "lemma"
+)
+
+type Normalizer struct {
+	name     string
+	stages   []func(string) []string
+	variants map[string]string
+}
+
+var defaultVariants = map[string]string{
+	"alright": "all right",
+	"cant":    "can't",
+	"didnt":   "didn't",
+	"doesnt":  "doesn't",
+	"dont":    "don't",
+	"gonna":   "going to",
+	"gotta":   "got to",
+	"im":      "i'm",
+	"isnt":    "isn't",
+	"ive":     "i've",
+	"kinda":   "kind of",
+	"ok":      "okay",
+	"sorta":   "sort of",
+	"thanx":   "thanks",
+	"thx":     "thanks",
+	"wanna":   "want to",
+	"youre":   "you're",
+	"youve":   "you've",
+}
+
+var contractions = map[string]string{
+	"ain't":   "is not",
+	"can't":   "cannot",
+	"he's":    "he is",
+	"here's":  "here is",
+	"it's":    "it is",
+	"let's":   "let us",
+	"she's":   "she is",
+	"shan't":  "shall not",
+	"that's":  "that is",
+	"there's": "there is",
+	"what's":  "what is",
+	"where's": "where is",
+	"who's":   "who is",
+	"won't":   "will not",
+	"y'all":   "you all",
+}
+
+var contractionSuffixes = [][2]string{
+	{"n't", " not"},
+	{"'re", " are"},
+	{"'ve", " have"},
+	{"'ll", " will"},
+	{"'m", " am"},
+	{"'d", " would"},
+}
+
+var lemmas = map[string]string{
+	"am": "be", "are": "be", "is": "be", "was": "be", "were": "be", "been": "be", "being": "be",
+	"has": "have", "had": "have", "having": "have",
+	"does": "do", "did": "do", "done": "do", "doing": "do",
+	"goes": "go", "went": "go", "gone": "go", "going": "go",
+	"says": "say", "said": "say",
+	"made": "make", "making": "make",
+	"came": "come", "coming": "come",
+	"took": "take", "taken": "take", "taking": "take",
+	"gave": "give", "given": "give", "giving": "give",
+	"got": "get", "gotten": "get", "getting": "get",
+	"knew": "know", "known": "know",
+	"unite": "unite", "unites": "unite", "united": "unite", "uniting": "unite",
+	"thought": "think",
+	"told":    "tell",
+	"using":   "use", "used": "use",
+	"children": "child", "men": "man", "women": "woman", "people": "person",
+	"better": "good", "best": "good",
+	"news": "news", "series": "series", "species": "species",
+}
+
+var notInflected = map[string]bool{
+	"always": true, "perhaps": true, "sometimes": true, "nowadays": true, "towards": true,
+	"afterwards": true, "besides": true, "whereas": true, "politics": true, "economics": true,
+	"physics": true, "ethics": true, "lens": true,
+	"during": true, "nothing": true, "something": true, "anything": true, "everything": true,
+	"morning": true, "evening": true, "ceiling": true, "wedding": true, "awning": true,
+	"hundred": true, "sacred": true, "naked": true, "wicked": true, "kindred": true, "rugged": true,
+}
+
+var baseForms = func() map[string]bool {
+	forms := map[string]bool{}
+	for _, lemma := range lemmas {
+		forms[lemma] = true
+	}
+	return forms
+}()
+
+func MakeNormalizer(name string, variants map[string]string) (*Normalizer, error) {
+	n := &Normalizer{variants: map[string]string{}}
+	for word, variant := range defaultVariants {
+		n.variants[word] = variant
+	}
+	for word, variant := range variants {
+		n.variants[strings.ToLower(word)] = strings.ToLower(variant)
+	}
+
+	stages := map[string]bool{}
+	for _, stage := range strings.Split(name, ",") {
+		switch stage = strings.TrimSpace(stage); stage {
+		case "", NormalizeRaw:
+		case "normalized":
+			stages[NormalizeVariants] = true
+			stages[NormalizeContractions] = true
+			stages[NormalizeLemma] = true
+		case NormalizeVariants, NormalizeContractions, NormalizeLemma:
+			stages[stage] = true
+		default:
+			return nil, fmt.Errorf("Unknown normalization: %s", stage)
+		}
+	}
+
+	names := []string{}
+	for _, stage := range []string{NormalizeVariants, NormalizeContractions, NormalizeLemma} {
+		if !stages[stage] {
+			continue
+		}
+		names = append(names, stage)
+		switch stage {
+		case NormalizeVariants:
+			n.stages = append(n.stages, n.variant)
+		case NormalizeContractions:
+			n.stages = append(n.stages, expandContraction)
+		case NormalizeLemma:
+			n.stages = append(n.stages, func(word string) []string {
+				return []string{Lemma(word)}
+			})
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, NormalizeRaw)
+	}
+	n.name = strings.Join(names, ",")
+	return n, nil
+}
+
+func (n *Normalizer) Name() string {
+	if n == nil {
+		return NormalizeRaw
+	}
+	return n.name
+}
+
+func (n *Normalizer) variant(word string) []string {
+	if variant, ok := n.variants[word]; ok {
+		return strings.Fields(variant)
+	}
+	return []string{word}
+}
+
+func expandContraction(word string) []string {
+	if expansion, ok := contractions[word]; ok {
+		return strings.Fields(expansion)
+	}
+	for _, suffix := range contractionSuffixes {
+		if stem, ok := strings.CutSuffix(word, suffix[0]); ok && stem != "" {
+			return strings.Fields(stem + suffix[1])
+		}
+	}
+	return []string{word}
+}
+
+func Lemma(word string) string {
+	if lemma, ok := lemmas[word]; ok {
+		return lemma
+	}
+	if notInflected[word] || baseForms[word] {
+		return word
+	}
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 4 && strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return dropE(word[:len(word)-1])
+	case len(word) > 5 && strings.HasSuffix(word, "ing") && hasVowel(word[:len(word)-3]):
+		return dropE(restoreE(word[:len(word)-3]))
+	case len(word) > 4 && strings.HasSuffix(word, "ied"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 4 && strings.HasSuffix(word, "ed") && !strings.HasSuffix(word, "eed") && hasVowel(word[:len(word)-2]):
+		return dropE(restoreE(word[:len(word)-2]))
+	}
+	return dropE(word)
+}
+
+func hasVowel(stem string) bool {
+	return strings.ContainsAny(stem, "aeiouy")
+}
+
+// restoreE puts back the "e" an inflection removed, as in "liked" and
+// "hoping", or undoubles the final consonant, as in "stopped", following
+// step 1b of the Porter stemmer.
+func restoreE(stem string) string {
+	n := len(stem)
+	switch {
+	case strings.HasSuffix(stem, "at") || strings.HasSuffix(stem, "bl") || strings.HasSuffix(stem, "iz"):
+		return stem + "e"
+	case n > 2 && stem[n-1] == stem[n-2] && !strings.ContainsRune("aeioulsz", rune(stem[n-1])):
+		return stem[:n-1]
+	case measure(stem) == 1 && endsCVC(stem):
+		return stem + "e"
+	}
+	return stem
+}
+
+// dropE removes a final "e" that restoreE would not put back, as step 5a
+// of the Porter stemmer does, so that "appreciate" and "appreciated" both
+// become "appreciat".
+func dropE(word string) string {
+	if len(word) <= 4 || !strings.HasSuffix(word, "e") || baseForms[word] {
+		return word
+	}
+	stem := word[:len(word)-1]
+	if m := measure(stem); m > 1 || (m == 1 && !endsCVC(stem)) {
+		return stem
+	}
+	return word
+}
+
+func isConsonant(word string, i int) bool {
+	switch word[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(word, i-1)
+	}
+	return true
+}
+
+func measure(stem string) int {
+	m := 0
+	for i := 1; i < len(stem); i++ {
+		if isConsonant(stem, i) && !isConsonant(stem, i-1) {
+			m++
+		}
+	}
+	return m
+}
+
+func endsCVC(stem string) bool {
+	n := len(stem)
+	return n >= 3 && isConsonant(stem, n-3) && !isConsonant(stem, n-2) && isConsonant(stem, n-1) && !strings.ContainsRune("wxy", rune(stem[n-1]))
+}
+
+func (n *Normalizer) Tokens(tokens []Token) []Token {
+	if n == nil || len(n.stages) == 0 {
+		return tokens
+	}
+	normalized := []Token{}
+	for _, token := range tokens {
+		word := token.Text
+		start := strings.IndexFunc(word, func(r rune) bool { return !trimFunc(r) })
+		if start < 0 {
+			normalized = append(normalized, token)
+			continue
+		}
+		end := strings.LastIndexFunc(word, func(r rune) bool { return !trimFunc(r) })
+		_, size := utf8.DecodeRuneInString(word[end:])
+		end += size
+		core := []string{strings.ToLower(word[start:end])}
+		for _, stage := range n.stages {
+			next := []string{}
+			for _, w := range core {
+				next = append(next, stage(w)...)
+			}
+			core = next
+		}
+		if len(core) == 0 {
+			continue
+		}
+		core[0] = word[:start] + core[0]
+		core[len(core)-1] += word[end:]
+		for i, w := range core {
+			normalized = append(normalized, Token{
+				Text:        w,
+				Start:       token.Start,
+				End:         token.End,
+				SentenceEnd: token.SentenceEnd && i == len(core)-1,
+			})
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,61 @@
+package scraper
+
+import "testing"
+
+func TestLemma(t *testing.T) {
+	tests := map[string]string{
+		"thanks":      "thank",
+		"thanked":     "thank",
+		"thanking":    "thank",
+		"going":       "go",
+		"running":     "run",
+		"stopped":     "stop",
+		"studies":     "study",
+		"studied":     "study",
+		"classes":     "class",
+		"makes":       "make",
+		"bus":         "bus",
+		"this":        "this",
+		"nothing":     "nothing",
+		"something":   "something",
+		"during":      "during",
+		"always":      "always",
+		"perhaps":     "perhaps",
+		"morning":     "morning",
+		"hundred":     "hundred",
+		"string":      "string",
+		"spring":      "spring",
+		"indeed":      "indeed",
+		"news":        "news",
+		"like":        "like",
+		"liked":       "like",
+		"likes":       "like",
+		"liking":      "like",
+		"appreciate":  "appreciat",
+		"appreciated": "appreciat",
+		"appreciates": "appreciat",
+		"hope":        "hope",
+		"hoping":      "hope",
+		"hoped":       "hope",
+		"hopping":     "hop",
+		"unit":        "unit",
+		"united":      "unite",
+		"unite":       "unite",
+		"beating":     "beat",
+		"created":     "creat",
+		"create":      "creat",
+		"closed":      "close",
+		"close":       "close",
+		"welcome":     "welcom",
+		"welcomed":    "welcom",
+		"one":         "one",
+		"use":         "use",
+		"using":       "use",
+		"visited":     "visit",
+	}
+	for word, want := range tests {
+		if got := Lemma(word); got != want {
+			t.Errorf("Lemma(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
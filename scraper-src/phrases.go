@@ -6,7 +6,7 @@ import (
 )
 
 type Phraser struct {
-	words []string
+	words []Token
 
 	currentPhrase     []string
 	currentIndex      int
@@ -14,12 +14,24 @@ type Phraser struct {
 }
 
 func MakePhraser(maxWords int, text string) *Phraser {
+	return MakeTokenPhraser(maxWords, Tokenize(text))
+}
+
+func MakeCompatPhraser(maxWords int, text string) *Phraser {
+	return MakeTokenPhraser(maxWords, TokenizeCompat(text))
+}
+
+func MakeTokenPhraser(maxWords int, tokens []Token) *Phraser {
 	return &Phraser{
-		words:         strings.Split(text, " "),
+		words:         tokens,
 		currentPhrase: make([]string, maxWords),
 	}
 }
 
+func (p *Phraser) Normalize(n *Normalizer) {
+	p.words = n.Tokens(p.words)
+}
+
 func (p *Phraser) OnlyFirstWords(count int) {
 	if len(p.words) > count {
 		p.words = p.words[:count]
@@ -54,13 +66,13 @@ func (p *Phraser) Next() []string {
 	}
 	changedCurrent := false
 	for len(p.words) > 0 {
-		word := p.words[0]
+		word := p.words[0].Text
+		terminated := p.words[0].SentenceEnd
 		p.words = p.words[1:]
 		if word == "" {
 			continue
 		}
-		switch word[len(word)-1] {
-		case '.', ':', ')', ']', '}', '!', '?', '"', '\'':
+		if terminated {
 			p.currentTerminated = true
 		}
 		word = strings.ToLower(strings.TrimFunc(word, trimFunc))
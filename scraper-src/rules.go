@@ -0,0 +1,110 @@
+package scraper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	fetcher "language-analysis/fetcher-src"
+)
+
+type rules struct {
+	start   *regexp.Regexp
+	content *regexp.Regexp
+	end     *regexp.Regexp
+}
+
+var defaultRules = rules{
+	start:   startMarker,
+	content: contentMarker,
+	end:     endMarker,
+}
+
+var feedRules = struct {
+	sync.Mutex
+	rules map[int64]rules
+}{
+	rules: map[int64]rules{},
+}
+
+func rulesFor(feedID int64) (rules, error) {
+	feedRules.Lock()
+	defer feedRules.Unlock()
+
+	if r, ok := feedRules.rules[feedID]; ok {
+		return r, nil
+	}
+
+	feed, err := fetcher.FeedByID(feedID)
+	if err != nil {
+		return rules{}, err
+	}
+	r, err := compileRules(feed.ExtractRules())
+	if err != nil {
+		return rules{}, fmt.Errorf("Invalid extraction rules for feed %d: %v", feedID, err)
+	}
+	feedRules.rules[feedID] = r
+	return r, nil
+}
+
+func (r rules) stamp() string {
+	return r.start.String() + "\n" + r.content.String() + "\n" + r.end.String()
+}
+
+func compileRules(start, content, end string) (rules, error) {
+	r := defaultRules
+	if start != "" {
+		rx, err := compileMarker(start)
+		if err != nil {
+			return rules{}, err
+		}
+		r.start = rx
+	}
+	if content != "" {
+		rx, err := compileMarker(content)
+		if err != nil {
+			return rules{}, err
+		}
+		r.content = rx
+	}
+	if end != "" {
+		rx, err := compileMarker(end)
+		if err != nil {
+			return rules{}, err
+		}
+		r.end = rx
+	}
+	return r, nil
+}
+
+var selectorRegex = regexp.MustCompile(`^(/?)([a-zA-Z][a-zA-Z0-9]*)?(?:([.#])([-_a-zA-Z0-9]+))?$`)
+
+func compileMarker(marker string) (*regexp.Regexp, error) {
+	if rx, ok := strings.CutPrefix(marker, "re:"); ok {
+		return regexp.Compile(rx)
+	}
+
+	match := selectorRegex.FindStringSubmatch(marker)
+	if match == nil || (match[2] == "" && match[4] == "") {
+		return nil, fmt.Errorf("Invalid selector: %s", marker)
+	}
+	tag := `[a-zA-Z][a-zA-Z0-9]*`
+	if match[2] != "" {
+		tag = regexp.QuoteMeta(match[2])
+	}
+	if match[1] == "/" {
+		if match[4] != "" {
+			return nil, fmt.Errorf("Invalid selector: %s", marker)
+		}
+		return regexp.Compile(`(?i)</` + tag + `\s*>`)
+	}
+	switch match[3] {
+	case ".":
+		return regexp.Compile(`(?i)<` + tag + `\b[^>]*\bclass=["'][^"']*\b` + regexp.QuoteMeta(match[4]) + `\b[^"']*["'][^>]*>`)
+	case "#":
+		return regexp.Compile(`(?i)<` + tag + `\b[^>]*\bid=["']` + regexp.QuoteMeta(match[4]) + `["'][^>]*>`)
+	default:
+		return regexp.Compile(`(?i)<` + tag + `(\s[^>]*)?>`)
+	}
+}
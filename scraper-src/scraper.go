@@ -1,7 +1,6 @@
 package scraper
 
 import (
-	"bytes"
 	"regexp"
 
 	fetcher "language-analysis/fetcher-src"
@@ -12,25 +11,29 @@ var contentMarker = regexp.MustCompile(`<p>`)
 var endMarker = regexp.MustCompile(`</div>`)
 
 func Scrape(file fetcher.File) ([]Transcript, error) {
+	r, err := rulesFor(file.FeedID())
+	if err != nil {
+		return nil, err
+	}
 	data, err := file.Contents()
 	if err != nil {
 		return nil, err
 	}
-	return toTranscript(scrapeContents(data)), nil
+	return toTranscript(scrapeContents(data, r)), nil
 }
 
-func scrapeContents(data []byte) []string {
-	loc := startMarker.FindIndex(data)
+func scrapeContents(data []byte, r rules) []string {
+	loc := r.start.FindIndex(data)
 	if loc == nil {
 		return nil
 	}
 	data = data[loc[1]:]
-	loc = endMarker.FindIndex(data)
+	loc = r.end.FindIndex(data)
 	if loc != nil {
 		data = data[:loc[0]]
 	}
 
-	loc = contentMarker.FindIndex(data)
+	loc = r.content.FindIndex(data)
 	if loc == nil {
 		return nil
 	}
@@ -38,15 +41,17 @@ func scrapeContents(data []byte) []string {
 
 	items := []string{}
 	for {
-		loc := contentMarker.FindIndex(data)
+		end := len(data)
+		loc := r.content.FindIndex(data)
+		if loc != nil {
+			end = loc[0]
+		}
+		if text := htmlText(data[:end]); text != "" {
+			items = append(items, text)
+		}
 		if loc == nil {
 			return items
 		}
-		if loc[0] > 0 {
-			buf := bytes.Buffer{}
-			buf.Write(data[:loc[0]])
-			items = append(items, buf.String())
-		}
 		data = data[loc[1]:]
 	}
 }
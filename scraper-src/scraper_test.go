@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestScrapeGolden(t *testing.T) {
+	pages, err := filepath.Glob("testdata/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) == 0 {
+		t.Fatal("No saved pages in testdata")
+	}
+	for _, page := range pages {
+		t.Run(filepath.Base(page), func(t *testing.T) {
+			data, err := os.ReadFile(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Builder{}
+			for _, turn := range toTranscript(scrapeContents(data, defaultRules)) {
+				fmt.Fprintf(&got, "%s\t%s\n", turn.Role, turn)
+			}
+
+			golden := strings.TrimSuffix(page, ".html") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("Scraped %s:\n%s\nwant:\n%s", page, got.String(), want)
+			}
+		})
+	}
+}
+
+func TestHTMLText(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"Hello <em>there</em>, <a href=\"x?a=1&amp;b=2\">friend</a>.", "Hello there, friend."},
+		{"Q&amp;A &#8217;n&#x2019; &lt;b&gt;", "Q&A 'n' <b>"},
+		{"“Quoted” ‘single’", "\"Quoted\" 'single'"},
+		{"one</p>\n<p>two<br>three", "one two three"},
+		{"a <script>if (x < 1) {}</script>b<style>p{}</style> c", "a b c"},
+		{"a <!-- comment --> b", "a b"},
+		{"5 < 6 and 7 > 3", "5 < 6 and 7 > 3"},
+		{"  \n\t ", ""},
+	}
+	for _, test := range tests {
+		if got := htmlText([]byte(test.html)); got != test.want {
+			t.Errorf("htmlText(%q) = %q, want %q", test.html, got, test.want)
+		}
+	}
+}
+
+func TestAssignRoles(t *testing.T) {
+	tests := []struct {
+		text []string
+		want map[string]string
+	}{
+		{
+			[]string{"ANN: Welcome.", "BOB: Thanks.", "ANN: So.", "BOB: Well.", "ANN: Bye.", "BOB: Bye."},
+			map[string]string{"ANN": RoleHost, "BOB": RoleGuest},
+		},
+		{
+			[]string{"ANN: Hi.", "BOB SMITH: Hello.", "BOB SMITH: So.", "ANN: Yes.", "BOB SMITH: Now.", "BOB SMITH: Bye."},
+			map[string]string{"ANN": RoleGuest, "BOB SMITH": RoleHost},
+		},
+		{
+			[]string{"ANN SMITH, HOST: We have Bob Jones on the line from Ohio.", "BOB JONES: Hi.", "ANN SMITH: And Carl Lee joins us.", "CARL LEE: Hello.", "ANN SMITH: Back to the phones.", "DANA: Hi, Ann."},
+			map[string]string{"ANN SMITH": RoleHost, "BOB JONES": RoleCaller, "CARL LEE": RoleGuest, "DANA": RoleCaller},
+		},
+		{
+			[]string{"ANN SMITH, HOST: Bob Jones is on the line.", "BOB JONES, SENATOR: Hello."},
+			map[string]string{"ANN SMITH": RoleHost, "BOB JONES": RoleGuest},
+		},
+	}
+	for _, test := range tests {
+		if got := Roles(toTranscript(test.text)); fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("Roles(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
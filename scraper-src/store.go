@@ -0,0 +1,232 @@
+package scraper
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"language-analysis/config"
+	fetcher "language-analysis/fetcher-src"
+	migrations "language-analysis/migrations-src"
+)
+
+const Version = 3
+
+var ErrPurged = errors.New("File purged before its transcript was stored")
+
+var Schema = migrations.Database{
+	Name:     "transcripts",
+	Filename: "transcripts.db",
+	Migrations: []migrations.Migration{
+		migrations.Migration{
+			Name:  "Create transcripts",
+			Probe: "SELECT fileID FROM transcripts LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE transcripts (
+					fileID INTEGER PRIMARY KEY,
+					scraperVersion INTEGER NOT NULL,
+					rules TEXT NOT NULL,
+					fetchTimestamp TIMESTAMP)`,
+				`CREATE TABLE turns (
+					fileID INTEGER REFERENCES transcripts (fileID),
+					turnIndex INTEGER,
+					speaker TEXT,
+					name TEXT,
+					title TEXT,
+					role TEXT,
+					text TEXT,
+					PRIMARY KEY (fileID, turnIndex))`,
+			},
+		},
+	},
+}
+
+type transcriptDB struct {
+	db *sql.DB
+}
+
+func openTranscriptDB() (*transcriptDB, error) {
+	db, err := sql.Open("sqlite3", config.Dir()+"/"+Schema.Filename+"?_busy_timeout=10000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+
+	tdb := transcriptDB{db}
+	if err := tdb.init(); err != nil {
+		tdb.Close()
+		return nil, err
+	}
+	return &tdb, nil
+}
+
+func (db *transcriptDB) Close() error {
+	return db.db.Close()
+}
+
+func (db *transcriptDB) init() error {
+	return Schema.Apply(db.db)
+}
+
+func (db *transcriptDB) stamp(fileID int64) (int, string, time.Time, bool, error) {
+	var version int
+	var rules string
+	var fetchTimestamp sql.NullString
+	err := db.db.QueryRow("SELECT scraperVersion, rules, fetchTimestamp FROM transcripts WHERE fileID = ?", fileID).Scan(&version, &rules, &fetchTimestamp)
+	if err == sql.ErrNoRows {
+		return 0, "", time.Time{}, false, nil
+	} else if err != nil {
+		return 0, "", time.Time{}, false, err
+	}
+	t, _ := time.Parse(time.RFC3339, fetchTimestamp.String)
+	return version, rules, t, true, nil
+}
+
+func (db *transcriptDB) transcript(fileID int64) ([]Transcript, error) {
+	rows, err := db.db.Query("SELECT turnIndex, speaker, name, title, role, text FROM turns WHERE fileID = ? ORDER BY turnIndex", fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transcript := []Transcript{}
+	for rows.Next() {
+		ts := Transcript{}
+		if err := rows.Scan(&ts.Index, &ts.Speaker, &ts.Name, &ts.Title, &ts.Role, &ts.Text); err != nil {
+			return nil, err
+		}
+		transcript = append(transcript, ts)
+	}
+	return transcript, rows.Err()
+}
+
+func (db *transcriptDB) store(fileID int64, rules string, fetchTimestamp time.Time, transcript []Transcript) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM turns WHERE fileID = ?", fileID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO transcripts (fileID, scraperVersion, rules, fetchTimestamp) VALUES (?,?,?,?)", fileID, Version, rules, fetchTimestamp.Format(time.DateTime)); err != nil {
+		return err
+	}
+	for _, ts := range transcript {
+		if _, err := tx.Exec("INSERT INTO turns (fileID, turnIndex, speaker, name, title, role, text) VALUES (?,?,?,?,?,?,?)", fileID, ts.Index, ts.Speaker, ts.Name, ts.Title, ts.Role, ts.Text); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (db *transcriptDB) counts() (int, int, error) {
+	var current, stale int
+	if err := db.db.QueryRow("SELECT COUNT(*) FILTER (WHERE scraperVersion = ?), COUNT(*) FILTER (WHERE scraperVersion <> ?) FROM transcripts", Version, Version).Scan(&current, &stale); err != nil {
+		return 0, 0, err
+	}
+	return current, stale, nil
+}
+
+func Load(file fetcher.File) ([]Transcript, error) {
+	db, err := openTranscriptDB()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open transcript database: %v", err)
+	}
+	defer db.Close()
+
+	return load(file, db)
+}
+
+// Store makes sure the transcript of file is stored before its HTML is
+// purged.  A file that is already gone has nothing left to store.
+func Store(file fetcher.File) error {
+	if _, err := Load(file); err != nil && !errors.Is(err, ErrPurged) && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func load(file fetcher.File, db *transcriptDB) ([]Transcript, error) {
+	r, err := rulesFor(file.FeedID())
+	if err != nil {
+		return nil, err
+	}
+
+	version, rules, fetchTimestamp, ok, err := db.stamp(file.ID())
+	if err != nil {
+		return nil, err
+	}
+	if ok && version == Version && rules == r.stamp() && !fetchTimestamp.Before(file.FetchTimestamp()) {
+		return db.transcript(file.ID())
+	}
+
+	data, err := file.Contents()
+	if errors.Is(err, fs.ErrNotExist) && !file.PurgeTimestamp().IsZero() {
+		if ok {
+			return db.transcript(file.ID())
+		}
+		return nil, ErrPurged
+	} else if err != nil {
+		return nil, err
+	}
+
+	transcript := toTranscript(scrapeContents(data, r))
+	if err := db.store(file.ID(), r.stamp(), file.FetchTimestamp(), transcript); err != nil {
+		return nil, fmt.Errorf("Failed to store transcript for file %d: %v", file.ID(), err)
+	}
+	return transcript, nil
+}
+
+func StatusCommand() error {
+	db, err := openTranscriptDB()
+	if err != nil {
+		return fmt.Errorf("Failed to open transcript database: %v", err)
+	}
+	defer db.Close()
+
+	current, stale, err := db.counts()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Scraper version %d: %d transcript(s) stored, %d from older versions.\n", Version, current, stale)
+	return nil
+}
+
+func ExtractCommand() error {
+	db, err := openTranscriptDB()
+	if err != nil {
+		return fmt.Errorf("Failed to open transcript database: %v", err)
+	}
+	defer db.Close()
+
+	to := time.Now().UTC().AddDate(1, 0, 0)
+	stored, skipped := 0, 0
+	var afterFileID int64
+	for {
+		files, err := fetcher.FilesByDate(time.Time{}, to, afterFileID, 100)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			break
+		}
+		for _, file := range files {
+			if _, err := load(file, db); errors.Is(err, ErrPurged) {
+				skipped++
+			} else if err != nil {
+				return fmt.Errorf("Failed to extract file %d: %v", file.ID(), err)
+			} else {
+				stored++
+			}
+			afterFileID = file.ID()
+		}
+	}
+	fmt.Printf("%d transcript(s) stored, %d purged file(s) unavailable.\n", stored, skipped)
+	return nil
+}
@@ -0,0 +1,171 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Token struct {
+	Text        string
+	Start       int
+	End         int
+	SentenceEnd bool
+}
+
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "st": true, "jr": true, "sr": true,
+	"prof": true, "gen": true, "sen": true, "rep": true, "gov": true, "lt": true,
+	"col": true, "sgt": true, "capt": true, "vs": true, "rev": true, "ft": true, "mt": true,
+}
+
+func Tokenizer(name string) (func(string) []Token, error) {
+	switch name {
+	case "", "sentences":
+		return Tokenize, nil
+	case "compat":
+		return TokenizeCompat, nil
+	default:
+		return nil, fmt.Errorf("Unknown tokenizer: %s", name)
+	}
+}
+
+func Tokenize(text string) []Token {
+	tokens := []Token{}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+
+		start := i
+		end := scanWord(text, i)
+		token := Token{Text: text[start:end], Start: start, End: end}
+		i = end
+
+		gap := i
+		for gap < len(text) {
+			r, size := utf8.DecodeRuneInString(text[gap:])
+			if isWordRune(r) {
+				break
+			}
+			gap += size
+		}
+		token.SentenceEnd = endsSentence(token.Text, text[i:gap], text[gap:])
+		tokens = append(tokens, token)
+	}
+	if len(tokens) > 0 {
+		tokens[len(tokens)-1].SentenceEnd = true
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+func scanWord(text string, i int) int {
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if isWordRune(r) {
+			i += size
+			continue
+		}
+		next, nextSize := utf8.DecodeRuneInString(text[i+size:])
+		if i+size >= len(text) || !isWordRune(next) {
+			break
+		}
+		switch r {
+		case '\'', '’', '-', '.':
+			i += size + nextSize
+			continue
+		case ',':
+			prev, _ := utf8.DecodeLastRuneInString(text[:i])
+			if unicode.IsDigit(prev) && unicode.IsDigit(next) {
+				i += size + nextSize
+				continue
+			}
+		}
+		break
+	}
+	return i
+}
+
+func endsSentence(word, gap, rest string) bool {
+	if strings.Contains(gap, "\n") {
+		return true
+	}
+	punctuation := strings.TrimRight(gap, " \t\"'”’)]}")
+	switch {
+	case punctuation == "":
+		return false
+	case strings.ContainsAny(punctuation, "!?"):
+		return true
+	case strings.HasPrefix(punctuation, "...") || strings.HasPrefix(punctuation, "…"):
+		r, _ := utf8.DecodeRuneInString(rest)
+		return rest == "" || unicode.IsUpper(r)
+	case strings.HasPrefix(punctuation, "."):
+		if abbreviations[strings.ToLower(word)] || strings.Contains(word, ".") {
+			return false
+		}
+		if r, size := utf8.DecodeRuneInString(word); size == len(word) && unicode.IsUpper(r) {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func TokenizeCompat(text string) []Token {
+	tokens := []Token{}
+	start := 0
+	for _, word := range strings.Split(text, " ") {
+		token := Token{Text: word, Start: start, End: start + len(word)}
+		if word != "" {
+			switch word[len(word)-1] {
+			case '.', ':', ')', ']', '}', '!', '?', '"', '\'':
+				token.SentenceEnd = true
+			}
+		}
+		tokens = append(tokens, token)
+		start += len(word) + 1
+	}
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].Text != "" {
+			tokens[i].SentenceEnd = true
+			break
+		}
+	}
+	return tokens
+}
+
+func Sentences(tokens []Token) [][]string {
+	sentences, _ := SentenceWords(tokens)
+	return sentences
+}
+
+func SentenceWords(tokens []Token) ([][]string, [][]int) {
+	sentences := [][]string{}
+	indexes := [][]int{}
+	sentence := []string{}
+	index := []int{}
+	for i, token := range tokens {
+		if word := strings.ToLower(strings.TrimFunc(token.Text, trimFunc)); word != "" {
+			sentence = append(sentence, word)
+			index = append(index, i)
+		}
+		if token.SentenceEnd && len(sentence) > 0 {
+			sentences = append(sentences, sentence)
+			indexes = append(indexes, index)
+			sentence = []string{}
+			index = []int{}
+		}
+	}
+	if len(sentence) > 0 {
+		sentences = append(sentences, sentence)
+		indexes = append(indexes, index)
+	}
+	return sentences, indexes
+}
@@ -0,0 +1,241 @@
+package scraper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// baselinePhraser is MakePhraser as it was before the tokenizer, kept to
+// pin the n-gram output of the compat tokenizer.
+type baselinePhraser struct {
+	words []string
+
+	currentPhrase     []string
+	currentIndex      int
+	currentTerminated bool
+}
+
+func makeBaselinePhraser(maxWords int, text string) *baselinePhraser {
+	return &baselinePhraser{
+		words:         strings.Split(text, " "),
+		currentPhrase: make([]string, maxWords),
+	}
+}
+
+func (p *baselinePhraser) phrase() []string {
+	phrase := make([]string, len(p.currentPhrase))
+	copy(phrase, p.currentPhrase)
+	return phrase
+}
+
+func (p *baselinePhraser) pushWord(word string) {
+	copy(p.currentPhrase, p.currentPhrase[1:])
+	p.currentPhrase[len(p.currentPhrase)-1] = word
+}
+
+func (p *baselinePhraser) Next() []string {
+	if p.currentIndex == 0 {
+		if len(p.words) == 0 {
+			return nil
+		}
+		p.currentTerminated = false
+	}
+	if p.currentTerminated {
+		p.pushWord("")
+		p.currentIndex--
+		if p.currentIndex > 0 {
+			return p.phrase()
+		}
+		p.currentTerminated = false
+	}
+	changedCurrent := false
+	for len(p.words) > 0 {
+		word := p.words[0]
+		p.words = p.words[1:]
+		if word == "" {
+			continue
+		}
+		switch word[len(word)-1] {
+		case '.', ':', ')', ']', '}', '!', '?', '"', '\'':
+			p.currentTerminated = true
+		}
+		word = strings.ToLower(strings.TrimFunc(word, trimFunc))
+		if word == "" {
+			if p.currentTerminated {
+				if p.currentIndex > 0 {
+					if changedCurrent {
+						return p.phrase()
+					}
+					p.pushWord("")
+					changedCurrent = true
+					p.currentIndex--
+					if p.currentIndex > 0 {
+						return p.phrase()
+					}
+				}
+				p.currentTerminated = false
+			}
+			continue
+		}
+		changedCurrent = true
+		if p.currentIndex == len(p.currentPhrase) {
+			p.pushWord(word)
+			return p.phrase()
+		}
+		p.currentPhrase[p.currentIndex] = word
+		p.currentIndex++
+		if p.currentIndex == len(p.currentPhrase) || p.currentTerminated {
+			return p.phrase()
+		}
+	}
+	p.currentTerminated = true
+	if changedCurrent {
+		return p.phrase()
+	}
+	p.pushWord("")
+	p.currentIndex--
+	if p.currentIndex > 0 {
+		return p.phrase()
+	}
+	return nil
+}
+
+var compatTexts = []string{
+	"You bet.",
+	"Thanks for joining us",
+	"Well — I think so.",
+	"Well—I think so... Maybe not.",
+	"Mr. Smith went to Washington. Mrs. Jones stayed home.",
+	"The U.S. economy grew 3.5 percent, or $3.5 trillion.",
+	"First line\nsecond line. Third\n\nline",
+	"  leading and trailing spaces  ",
+	"\"Quoted,\" she said: 'yes' (maybe) [sic] {ok}!",
+	"Is it? It is! It is.",
+	"...",
+	"",
+	"one",
+	"a b c d e f g h i j k",
+}
+
+func TestCompatPhraserMatchesBaseline(t *testing.T) {
+	for _, text := range compatTexts {
+		for _, maxWords := range []int{1, 3, 5} {
+			for _, first := range []int{0, 2, 6} {
+				want := [][]string{}
+				baseline := makeBaselinePhraser(maxWords, text)
+				if first > 0 && len(baseline.words) > first {
+					baseline.words = baseline.words[:first]
+				}
+				for phrase := baseline.Next(); phrase != nil; phrase = baseline.Next() {
+					want = append(want, phrase)
+				}
+
+				got := [][]string{}
+				p := MakeCompatPhraser(maxWords, text)
+				if first > 0 {
+					p.OnlyFirstWords(first)
+				}
+				for phrase := p.Next(); phrase != nil; phrase = p.Next() {
+					got = append(got, phrase)
+				}
+
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("MakeCompatPhraser(%d, %q) with %d words:\n got %q\nwant %q", maxWords, text, first, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestTokenizeSentences(t *testing.T) {
+	tests := []struct {
+		text string
+		want [][]string
+	}{
+		{"Well—I think so... Maybe not.", [][]string{{"well", "i", "think", "so"}, {"maybe", "not"}}},
+		{"Mr. Smith went to Washington. Mrs. Jones stayed.", [][]string{{"mr", "smith", "went", "to", "washington"}, {"mrs", "jones", "stayed"}}},
+		{"The U.S. economy grew 3.5 percent.", [][]string{{"the", "u.s", "economy", "grew", "3.5", "percent"}}},
+		{"First line\nsecond line", [][]string{{"first", "line"}, {"second", "line"}}},
+		{"Thanks for joining us", [][]string{{"thanks", "for", "joining", "us"}}},
+	}
+	for _, test := range tests {
+		if got := Sentences(Tokenize(test.text)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Sentences(Tokenize(%q)) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestTokenizeCompatEndsLastSentence(t *testing.T) {
+	for _, text := range []string{"Thanks for joining us", "Thanks for joining us ", "Thanks."} {
+		tokens := TokenizeCompat(text)
+		last := -1
+		for i, token := range tokens {
+			if token.Text != "" {
+				last = i
+			}
+		}
+		if last < 0 || !tokens[last].SentenceEnd {
+			t.Errorf("TokenizeCompat(%q) does not end the last sentence: %+v", text, tokens)
+		}
+		for _, token := range tokens {
+			if text[token.Start:token.End] != token.Text {
+				t.Errorf("TokenizeCompat(%q) token %q has offsets %d-%d", text, token.Text, token.Start, token.End)
+			}
+		}
+	}
+}
+
+func TestMakeAnalyzer(t *testing.T) {
+	a, err := MakeAnalyzer("", "lemma", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.TokenizerName() != "sentences" || a.NormalizationName() != NormalizeLemma {
+		t.Errorf("MakeAnalyzer(\"\", \"lemma\") is %s, %s", a.TokenizerName(), a.NormalizationName())
+	}
+	if got, want := a.Sentences("Thanks. Mr. Smith"), [][]string{{"thank"}, {"mr", "smith"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sentences = %q, want %q", got, want)
+	}
+	if got := a.CountWords("Mr. Smith -- thanks!"); got != 3 {
+		t.Errorf("CountWords = %d, want 3", got)
+	}
+	if c, err := MakeAnalyzer("", "contractions", nil); err != nil {
+		t.Fatal(err)
+	} else if got := c.CountWords("You're welcome."); got != 3 {
+		t.Errorf("CountWords with contractions = %d, want 3", got)
+	}
+	if got := (Analyzer{}).TokenizerName(); got != "sentences" {
+		t.Errorf("Default tokenizer %s", got)
+	}
+	if _, err := MakeAnalyzer("words", "", nil); err == nil {
+		t.Errorf("MakeAnalyzer accepted an unknown tokenizer")
+	}
+	if _, err := MakeAnalyzer("compat", "stems", nil); err == nil {
+		t.Errorf("MakeAnalyzer accepted an unknown normalization")
+	}
+}
+
+func TestReportFilters(t *testing.T) {
+	a, err := MakeAnalyzer("compat", "lemma", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tokenizer, normalization string
+		wantTokenizer, wantNorm  string
+	}{
+		{"", "", "compat", NormalizeLemma},
+		{"all", "all", "", ""},
+		{"sentences", "normalized", "sentences", "variants,contractions,lemma"},
+	}
+	for _, test := range tests {
+		tokenizer, normalization, err := a.ReportFilters(test.tokenizer, test.normalization)
+		if err != nil || tokenizer != test.wantTokenizer || normalization != test.wantNorm {
+			t.Errorf("ReportFilters(%q, %q) = %q, %q, %v", test.tokenizer, test.normalization, tokenizer, normalization, err)
+		}
+	}
+	if _, _, err := a.ReportFilters("words", ""); err == nil {
+		t.Errorf("ReportFilters accepted an unknown tokenizer")
+	}
+}
@@ -2,13 +2,23 @@ package scraper
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
+const (
+	RoleHost          = "host"
+	RoleGuest         = "guest"
+	RoleCorrespondent = "correspondent"
+	RoleCaller        = "caller"
+)
+
 type Transcript struct {
 	Index   int
 	Speaker string
 	Name    string
+	Title   string
+	Role    string
 	Text    string
 }
 
@@ -26,6 +36,7 @@ func (t Transcript) String() string {
 
 func toTranscript(text []string) []Transcript {
 	names := map[string]string{}
+	titles := map[string]string{}
 	transcript := []Transcript{}
 	for index, item := range text {
 		i := strings.Index(item, ":")
@@ -41,6 +52,7 @@ func toTranscript(text []string) []Transcript {
 		name := speaker
 		comma := strings.Index(name, ",")
 		if comma > 0 {
+			titles[strings.Trim(name[:comma], " ")] = strings.Trim(name[comma+1:], " ")
 			name = strings.Trim(name[:comma], " ")
 			names[name] = name
 		} else if n, ok := names[name]; ok {
@@ -89,5 +101,105 @@ func toTranscript(text []string) []Transcript {
 			Text:    text,
 		})
 	}
+	return assignRoles(transcript, titles)
+}
+
+// callIn matches the ways a host brings a caller on the air.
+var callIn = regexp.MustCompile(`(?i)\b(on the line|to the phones|phone lines|our callers?|calling (in )?from|you're on the air)\b`)
+
+func assignRoles(transcript []Transcript, titles map[string]string) []Transcript {
+	roles := map[string]string{}
+	turns := map[string]int{}
+	speakers := []string{}
+	hasHost := false
+	for _, ts := range transcript {
+		if ts.Name == "" {
+			continue
+		}
+		if _, ok := roles[ts.Name]; !ok {
+			roles[ts.Name] = titleRole(ts.Name, titles[ts.Name])
+			speakers = append(speakers, ts.Name)
+			hasHost = hasHost || roles[ts.Name] == RoleHost
+		}
+		turns[ts.Name]++
+	}
+
+	// Without a titled host, the host is the untitled speaker who speaks
+	// most, counting opening the transcript as a turn.
+	if !hasHost && len(speakers) > 0 {
+		score := func(name string) int {
+			if name == speakers[0] {
+				return turns[name] + 1
+			}
+			return turns[name]
+		}
+		host := ""
+		for _, name := range speakers {
+			if roles[name] == "" && (host == "" || score(name) > score(host)) {
+				host = name
+			}
+		}
+		if host != "" {
+			roles[host] = RoleHost
+		}
+	}
+
+	// After the host takes a call, the next untitled speaker is a caller
+	// if they were introduced by name or go by one name.
+	cue := ""
+	for _, ts := range transcript {
+		if ts.Name == "" {
+			continue
+		}
+		if roles[ts.Name] == RoleHost {
+			if callIn.MatchString(ts.Text) {
+				cue = strings.ToLower(ts.Text)
+			}
+			continue
+		}
+		if cue != "" && roles[ts.Name] == "" && titles[ts.Name] == "" {
+			if !strings.Contains(ts.Name, " ") || strings.Contains(cue, strings.ToLower(ts.Name)) {
+				roles[ts.Name] = RoleCaller
+			}
+		}
+		cue = ""
+	}
+
+	for i := range transcript {
+		if transcript[i].Name == "" {
+			continue
+		}
+		transcript[i].Title = titles[transcript[i].Name]
+		transcript[i].Role = roles[transcript[i].Name]
+		if transcript[i].Role == "" {
+			transcript[i].Role = RoleGuest
+		}
+	}
 	return transcript
 }
+
+func titleRole(name, title string) string {
+	title = strings.ToUpper(title)
+	switch {
+	case strings.Contains(title, "HOST"):
+		return RoleHost
+	case strings.Contains(title, "BYLINE"), strings.Contains(title, "CORRESPONDENT"), strings.Contains(title, "REPORTER"):
+		return RoleCorrespondent
+	case strings.Contains(title, "CALLER"), strings.Contains(strings.ToUpper(name), "CALLER"):
+		return RoleCaller
+	case title != "":
+		return RoleGuest
+	default:
+		return ""
+	}
+}
+
+func Roles(transcript []Transcript) map[string]string {
+	roles := map[string]string{}
+	for _, ts := range transcript {
+		if ts.Name != "" {
+			roles[ts.Name] = ts.Role
+		}
+	}
+	return roles
+}
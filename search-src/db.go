@@ -0,0 +1,215 @@
+package search
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"language-analysis/config"
+	migrations "language-analysis/migrations-src"
+	scraper "language-analysis/scraper-src"
+)
+
+var Schema = migrations.Database{
+	Name:     "search",
+	Filename: "search.db",
+	Migrations: []migrations.Migration{
+		migrations.Migration{
+			Name:  "Create search index",
+			Probe: "SELECT lastFetchTimestamp FROM fetcherState LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE fetcherState (
+					lastFetchTimestamp TIMESTAMP)`,
+				`INSERT INTO fetcherState (lastFetchTimestamp)
+					VALUES ('1970-01-01 00:00:00')`,
+				`CREATE TABLE files (
+					fileID INTEGER PRIMARY KEY,
+					date DATE)`,
+				`CREATE INDEX fileDate ON files (date)`,
+				`CREATE TABLE turnInfo (
+					turnID INTEGER PRIMARY KEY AUTOINCREMENT,
+					fileID INTEGER REFERENCES files (fileID),
+					turnIndex INTEGER,
+					speaker TEXT,
+					registryID INTEGER)`,
+				`CREATE INDEX turnInfoFileID ON turnInfo (fileID)`,
+				`CREATE INDEX turnInfoRegistryID ON turnInfo (registryID)`,
+				`CREATE VIRTUAL TABLE turns USING fts4 (text, tokenize=unicode61)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add fetch cursor file IDs",
+			Probe: "SELECT lastFileID FROM fetcherState LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE fetcherState ADD COLUMN lastFileID INTEGER NOT NULL DEFAULT 0`,
+			},
+		},
+	},
+}
+
+type searchDB struct {
+	db *sql.DB
+}
+
+func openSearchDB() (*searchDB, error) {
+	db, err := sql.Open("sqlite3", config.Dir()+"/"+Schema.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	sdb := searchDB{db}
+	if err := sdb.init(); err != nil {
+		sdb.Close()
+		return nil, err
+	}
+	return &sdb, nil
+}
+
+func (db *searchDB) Close() error {
+	return db.db.Close()
+}
+
+func (db *searchDB) init() error {
+	return Schema.Apply(db.db)
+}
+
+func parseDate(dateString sql.NullString) time.Time {
+	t, _ := time.Parse(time.RFC3339, dateString.String)
+	return t
+}
+
+func parseTimestamp(timestampString sql.NullString) time.Time {
+	t, _ := time.Parse(time.RFC3339, timestampString.String)
+	return t
+}
+
+func (db *searchDB) fetchCursor() (time.Time, int64, error) {
+	var fetchTimestamp sql.NullString
+	var fileID int64
+	if err := db.db.QueryRow("SELECT lastFetchTimestamp, lastFileID FROM fetcherState LIMIT 1").Scan(&fetchTimestamp, &fileID); err != nil {
+		return time.Time{}, 0, err
+	}
+	return parseTimestamp(fetchTimestamp), fileID, nil
+}
+
+func (db *searchDB) setFetchCursor(lastFetchTimestamp time.Time, lastFileID int64) error {
+	_, err := db.db.Exec("UPDATE fetcherState SET lastFetchTimestamp = ?, lastFileID = ?", lastFetchTimestamp.Format(time.DateTime), lastFileID)
+	return err
+}
+
+func (db *searchDB) counts() (int, int, error) {
+	var files, turns int
+	if err := db.db.QueryRow("SELECT (SELECT COUNT(*) FROM files), (SELECT COUNT(*) FROM turnInfo)").Scan(&files, &turns); err != nil {
+		return 0, 0, err
+	}
+	return files, turns, nil
+}
+
+func (db *searchDB) indexFile(fileID int64, date, fetchTimestamp time.Time, transcript []scraper.Transcript, registryIDs map[string]int64) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM turns WHERE docid IN (SELECT turnID FROM turnInfo WHERE fileID = ?)", fileID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM turnInfo WHERE fileID = ?", fileID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO files (fileID, date) VALUES (?,?)", fileID, date.Format(time.DateOnly)); err != nil {
+		return err
+	}
+
+	for _, ts := range transcript {
+		var registryID sql.NullInt64
+		if id, ok := registryIDs[ts.Name]; ok {
+			registryID = sql.NullInt64{Int64: id, Valid: true}
+		}
+		result, err := tx.Exec("INSERT INTO turnInfo (fileID, turnIndex, speaker, registryID) VALUES (?,?,?,?)", fileID, ts.Index, ts.Name, registryID)
+		if err != nil {
+			return err
+		}
+		turnID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO turns (docid, text) VALUES (?,?)", turnID, ts.Text); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE fetcherState SET lastFetchTimestamp = ?, lastFileID = ?", fetchTimestamp.Format(time.DateTime), fileID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type hit struct {
+	Date    string `json:"date"`
+	FileID  int64  `json:"fileID"`
+	Index   int    `json:"index"`
+	Speaker string `json:"speaker"`
+	Snippet string `json:"snippet"`
+}
+
+type query struct {
+	match      string
+	from       time.Time
+	to         time.Time
+	registryID int64
+	limit      int
+}
+
+func (db *searchDB) search(q query) ([]hit, error) {
+	conditions := []string{"turns.text MATCH ?"}
+	args := []any{q.match}
+	if !q.from.IsZero() {
+		conditions = append(conditions, "files.date >= ?")
+		args = append(args, q.from.Format(time.DateOnly))
+	}
+	if !q.to.IsZero() {
+		conditions = append(conditions, "files.date <= ?")
+		args = append(args, q.to.Format(time.DateOnly))
+	}
+	if q.registryID != 0 {
+		conditions = append(conditions, "turnInfo.registryID = ?")
+		args = append(args, q.registryID)
+	}
+	limit := ""
+	if q.limit > 0 {
+		limit = "LIMIT ?"
+		args = append(args, q.limit)
+	}
+
+	rows, err := db.db.Query(`SELECT files.date, turnInfo.fileID, turnInfo.turnIndex, turnInfo.speaker,
+			snippet(turns, '>>', '<<', '...', 0, 20)
+		FROM turns
+		JOIN turnInfo ON turnInfo.turnID = turns.docid
+		JOIN files ON files.fileID = turnInfo.fileID
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY files.date, turnInfo.fileID, turnInfo.turnIndex
+		`+limit, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []hit{}
+	for rows.Next() {
+		h := hit{}
+		var date sql.NullString
+		var speaker sql.NullString
+		if err := rows.Scan(&date, &h.FileID, &h.Index, &speaker, &h.Snippet); err != nil {
+			return nil, err
+		}
+		h.Date = parseDate(date).Format(time.DateOnly)
+		h.Speaker = speaker.String
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
@@ -0,0 +1,141 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"language-analysis/config"
+	fetcher "language-analysis/fetcher-src"
+	scraper "language-analysis/scraper-src"
+	speakers "language-analysis/speakers-src"
+)
+
+func StatusCommand() error {
+	db, err := openSearchDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fetchTimestamp, fileID, err := db.fetchCursor()
+	if err != nil {
+		return err
+	}
+	files, turns, err := db.counts()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Last fetch timestamp: %s, file %d\n", fetchTimestamp.Format(time.DateTime), fileID)
+	fmt.Printf("%d file(s), %d turn(s) indexed.\n", files, turns)
+	return nil
+}
+
+func IndexCommand() error {
+	count := config.Int("search-index-count")
+
+	db, err := openSearchDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	indexed := 0
+	for indexed < count {
+		fetchTimestamp, fileID, err := db.fetchCursor()
+		if err != nil {
+			return err
+		}
+
+		files, err := fetcher.FilesAfter(fetchTimestamp, fileID, min(100, count-indexed))
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for _, file := range files {
+			content, err := scraper.Load(file)
+			if errors.Is(err, scraper.ErrPurged) {
+				if err := db.setFetchCursor(file.FetchTimestamp(), file.ID()); err != nil {
+					return err
+				}
+				continue
+			} else if err != nil {
+				return err
+			}
+			registryIDs, err := speakers.Resolve(slices.Collect(maps.Keys(scraper.Roles(content))))
+			if err != nil {
+				return err
+			}
+			if err := db.indexFile(file.ID(), file.Date(), file.FetchTimestamp(), content, registryIDs); err != nil {
+				return err
+			}
+			indexed++
+		}
+	}
+	fmt.Printf("Indexed %d file(s).\n", indexed)
+	return nil
+}
+
+func SearchCommand() error {
+	q := query{
+		match: strings.Join(config.Args(), " "),
+		limit: config.Int("search-limit"),
+	}
+	if q.match == "" {
+		return config.Usagef("Specify a query")
+	}
+	format := config.String("search-format")
+	var err error
+	if s := config.String("from"); s != "" {
+		if q.from, err = time.Parse(time.DateOnly, s); err != nil {
+			return fmt.Errorf("Invalid from date: %v", err)
+		}
+	}
+	if s := config.String("to"); s != "" {
+		if q.to, err = time.Parse(time.DateOnly, s); err != nil {
+			return fmt.Errorf("Invalid to date: %v", err)
+		}
+	}
+	if speaker := config.String("speaker"); speaker != "" {
+		s, err := speakers.Find(speaker)
+		if err != nil {
+			return err
+		}
+		q.registryID = s.ID()
+	}
+
+	db, err := openSearchDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	hits, err := db.search(q)
+	if err != nil {
+		return fmt.Errorf("Failed to search for %s: %v", q.match, err)
+	}
+
+	if format == "json" {
+		out := json.NewEncoder(os.Stdout)
+		for _, h := range hits {
+			if err := out.Encode(h); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, h := range hits {
+		fmt.Printf("%s,%d.%d: [%s] %s\n", h.Date, h.FileID, h.Index, h.Speaker, h.Snippet)
+	}
+	fmt.Printf("%d match(es).\n", len(hits))
+	return nil
+}
@@ -0,0 +1,207 @@
+package speakers
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"language-analysis/config"
+	migrations "language-analysis/migrations-src"
+)
+
+var Schema = migrations.Database{
+	Name:     "speakers",
+	Filename: "speakers.db",
+	Migrations: []migrations.Migration{
+		migrations.Migration{
+			Name:  "Create speakers and aliases",
+			Probe: "SELECT speakerID FROM speakers LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE speakers (
+					speakerID INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL)`,
+				`CREATE TABLE aliases (
+					alias TEXT PRIMARY KEY,
+					name TEXT NOT NULL,
+					speakerID INTEGER REFERENCES speakers (speakerID))`,
+				`CREATE INDEX aliasesSpeakerID ON aliases (speakerID)`,
+			},
+		},
+	},
+}
+
+type speakerDB struct {
+	db *sql.DB
+}
+
+func openSpeakerDB() (*speakerDB, error) {
+	db, err := sql.Open("sqlite3", config.Dir()+"/"+Schema.Filename+"?_busy_timeout=10000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+
+	sdb := speakerDB{db}
+	if err := sdb.init(); err != nil {
+		sdb.Close()
+		return nil, err
+	}
+	return &sdb, nil
+}
+
+func (db *speakerDB) Close() error {
+	return db.db.Close()
+}
+
+func (db *speakerDB) init() error {
+	return Schema.Apply(db.db)
+}
+
+func aliasKey(name string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(strings.Trim(name, " .,"))), " ")
+}
+
+func (db *speakerDB) resolve(names []string) (map[string]int64, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	speakerIDs := map[string]int64{}
+	for _, name := range names {
+		alias := aliasKey(name)
+		if alias == "" {
+			continue
+		}
+		var speakerID int64
+		err := tx.QueryRow("SELECT speakerID FROM aliases WHERE alias = ?", alias).Scan(&speakerID)
+		if err == sql.ErrNoRows {
+			result, err := tx.Exec("INSERT INTO speakers (name) VALUES (?)", strings.TrimSpace(name))
+			if err != nil {
+				return nil, err
+			}
+			if speakerID, err = result.LastInsertId(); err != nil {
+				return nil, err
+			}
+			if _, err := tx.Exec("INSERT INTO aliases (alias, name, speakerID) VALUES (?,?,?)", alias, strings.TrimSpace(name), speakerID); err != nil {
+				return nil, err
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		speakerIDs[name] = speakerID
+	}
+
+	return speakerIDs, tx.Commit()
+}
+
+func (db *speakerDB) find(speaker string) (Speaker, error) {
+	speakerID, err := strconv.ParseInt(speaker, 10, 64)
+	if err != nil {
+		if err := db.db.QueryRow("SELECT speakerID FROM aliases WHERE alias = ?", aliasKey(speaker)).Scan(&speakerID); err == sql.ErrNoRows {
+			return Speaker{}, fmt.Errorf("Unknown speaker: %s", speaker)
+		} else if err != nil {
+			return Speaker{}, err
+		}
+	}
+
+	s := Speaker{}
+	if err := db.db.QueryRow("SELECT speakerID, name FROM speakers WHERE speakerID = ?", speakerID).Scan(&s.speakerID, &s.name); err == sql.ErrNoRows {
+		return Speaker{}, fmt.Errorf("Nonexistent speakerID: %d", speakerID)
+	} else if err != nil {
+		return Speaker{}, err
+	}
+	return s, nil
+}
+
+func (db *speakerDB) speakers() ([]Speaker, error) {
+	rows, err := db.db.Query(`SELECT speakers.speakerID, speakers.name, aliases.name
+		FROM speakers
+		JOIN aliases ON aliases.speakerID = speakers.speakerID
+		ORDER BY speakers.name, speakers.speakerID, aliases.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	speakers := []Speaker{}
+	for rows.Next() {
+		var speakerID int64
+		var name, alias string
+		if err := rows.Scan(&speakerID, &name, &alias); err != nil {
+			return nil, err
+		}
+		if len(speakers) == 0 || speakers[len(speakers)-1].speakerID != speakerID {
+			speakers = append(speakers, Speaker{speakerID: speakerID, name: name})
+		}
+		speakers[len(speakers)-1].aliases = append(speakers[len(speakers)-1].aliases, alias)
+	}
+	return speakers, rows.Err()
+}
+
+func (db *speakerDB) merge(fromID, intoID int64) (int64, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE aliases SET speakerID = ? WHERE speakerID = ?", intoID, fromID)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("DELETE FROM speakers WHERE speakerID = ?", fromID); err != nil {
+		return 0, err
+	}
+
+	return moved, tx.Commit()
+}
+
+func (db *speakerDB) split(alias string) (int64, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var name string
+	var speakerID int64
+	if err := tx.QueryRow("SELECT name, speakerID FROM aliases WHERE alias = ?", aliasKey(alias)).Scan(&name, &speakerID); err == sql.ErrNoRows {
+		return 0, fmt.Errorf("Unknown alias: %s", alias)
+	} else if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM aliases WHERE speakerID = ?", speakerID).Scan(&count); err != nil {
+		return 0, err
+	}
+	if count < 2 {
+		return 0, fmt.Errorf("Alias %s is the only alias of speaker %d", name, speakerID)
+	}
+
+	result, err := tx.Exec("INSERT INTO speakers (name) VALUES (?)", name)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE aliases SET speakerID = ? WHERE alias = ?", newID, aliasKey(alias)); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE speakers SET name = (SELECT MIN(name) FROM aliases WHERE aliases.speakerID = speakers.speakerID)
+		WHERE speakerID = ? AND name = ?`, speakerID, name); err != nil {
+		return 0, err
+	}
+
+	return newID, tx.Commit()
+}
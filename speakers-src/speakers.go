@@ -0,0 +1,113 @@
+package speakers
+
+import (
+	"fmt"
+	"strings"
+
+	"language-analysis/config"
+)
+
+type Speaker struct {
+	speakerID int64
+	name      string
+	aliases   []string
+}
+
+func (speaker Speaker) ID() int64 {
+	return speaker.speakerID
+}
+
+func (speaker Speaker) Name() string {
+	return speaker.name
+}
+
+func Resolve(names []string) (map[string]int64, error) {
+	db, err := openSpeakerDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return db.resolve(names)
+}
+
+func Find(speaker string) (Speaker, error) {
+	db, err := openSpeakerDB()
+	if err != nil {
+		return Speaker{}, err
+	}
+	defer db.Close()
+
+	return db.find(speaker)
+}
+
+func ListCommand() error {
+	db, err := openSpeakerDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	speakers, err := db.speakers()
+	if err != nil {
+		return err
+	}
+	for _, speaker := range speakers {
+		fmt.Printf("Speaker %d: %s\n", speaker.speakerID, speaker.name)
+		if len(speaker.aliases) > 1 || speaker.aliases[0] != speaker.name {
+			fmt.Printf("  aliases: %s\n", strings.Join(speaker.aliases, "; "))
+		}
+	}
+	return nil
+}
+
+func MergeCommand() error {
+	if len(config.Args()) != 2 {
+		return config.Usagef("Specify the speaker to merge and the speaker to merge into")
+	}
+
+	db, err := openSpeakerDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	from, err := db.find(config.Args()[0])
+	if err != nil {
+		return err
+	}
+	into, err := db.find(config.Args()[1])
+	if err != nil {
+		return err
+	}
+	if from.speakerID == into.speakerID {
+		return fmt.Errorf("Cannot merge speaker %d into itself", from.speakerID)
+	}
+
+	moved, err := db.merge(from.speakerID, into.speakerID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Merged speaker %d (%s) into speaker %d (%s), moved %d alias(es).\n", from.speakerID, from.name, into.speakerID, into.name, moved)
+	return nil
+}
+
+func SplitCommand() error {
+	if len(config.Args()) != 1 {
+		return config.Usagef("Specify one alias")
+	}
+	alias := config.Args()[0]
+
+	db, err := openSpeakerDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	speakerID, err := db.split(alias)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Split %s into speaker %d.\n", alias, speakerID)
+	return nil
+}
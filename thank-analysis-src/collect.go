@@ -1,12 +1,16 @@
 package thankAnalysis
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
+	attach "language-analysis/attach-src"
 	"language-analysis/config"
-	fetcher "language-analysis/fetcher-src"
 	scraper "language-analysis/scraper-src"
+	speakers "language-analysis/speakers-src"
 )
 
 func StatusCommand() error {
@@ -16,20 +20,17 @@ func StatusCommand() error {
 	}
 	defer db.Close()
 
-	fetchTimestamp, err := db.lastFetchTimestamp()
+	fetchTimestamp, fileID, err := db.fetchCursor()
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Last fetch timestamp: %s\n", fetchTimestamp.Format(time.DateTime))
+	fmt.Printf("Last fetch timestamp: %s, file %d\n", fetchTimestamp.Format(time.DateTime), fileID)
 	return nil
 }
 
 func CollectCommand() error {
-	count, err := config.Int("thank-collect-count", 80)
-	if err != nil {
-		return err
-	}
+	count := config.Int("thank-collect-count")
 
 	db, err := openThankDB()
 	if err != nil {
@@ -37,13 +38,17 @@ func CollectCommand() error {
 	}
 	defer db.Close()
 
+	if err := db.syncSpeakers(); err != nil {
+		return err
+	}
+
 	for range count {
-		fetchTimestamp, err := db.lastFetchTimestamp()
+		fetchTimestamp, fileID, err := db.fetchCursor()
 		if err != nil {
 			return err
 		}
 
-		files, err := fetcher.FilesSince(fetchTimestamp, 1)
+		files, err := attach.FilesAfter(db.db, fetchTimestamp, fileID, 1)
 		if err != nil {
 			return err
 		}
@@ -53,22 +58,52 @@ func CollectCommand() error {
 		}
 
 		for _, file := range files {
-			content, err := scraper.Scrape(file)
-			if err != nil {
+			content, err := scraper.Load(file)
+			if errors.Is(err, scraper.ErrPurged) {
+				fmt.Printf("%s,%d: %v\n", file.Date().Format(time.DateOnly), file.ID(), err)
+				if err := db.setFetchCursor(file.FetchTimestamp(), file.ID()); err != nil {
+					return err
+				}
+				continue
+			} else if err != nil {
 				return err
 			}
 
-			responses := map[string]map[[5]string]bool{}
+			responses := map[string]response{}
 			for _, resp := range ThankResponses(content) {
 				fmt.Printf("%s,%d.%d: %s\n", file.Date().Format(time.DateOnly), file.ID(), resp.Index, resp)
-				responses[resp.Name] = ResponsePhrases(resp.Text)
+				responses[resp.Name] = response{
+					triggers: resp.Triggers,
+					phrases:  ResponsePhrases(resp.Text),
+				}
+			}
+
+			roles := scraper.Roles(content)
+			registryIDs, err := speakers.Resolve(slices.Collect(maps.Keys(roles)))
+			if err != nil {
+				return err
+			}
+
+			exchanges := []exchange{}
+			for _, e := range Exchanges(content) {
+				exchanges = append(exchanges, exchange{
+					triggers:        e.Triggers,
+					thankIndex:      e.Thank.Index,
+					responseIndex:   e.Response.Index,
+					thanker:         e.Thank.Name,
+					thanked:         e.Thanked,
+					responder:       e.Response.Name,
+					distance:        e.Distance,
+					thankPhrases:    ThankPhrases(e.Thank.Text, e.Triggers...),
+					responsePhrases: ResponsePhrases(e.Response.Text),
+				})
 			}
 
-			if err := db.addResponses(file.ID(), file.Date(), responses); err != nil {
+			if err := db.addResponses(file.ID(), file.Date(), analyzer.NormalizationName(), analyzer.TokenizerName(), roles, registryIDs, responses, exchanges); err != nil {
 				return err
 			}
 
-			if err := db.setFetchTimestamp(file.FetchTimestamp()); err != nil {
+			if err := db.setFetchCursor(file.FetchTimestamp(), file.ID()); err != nil {
 				return err
 			}
 		}
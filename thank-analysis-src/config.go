@@ -0,0 +1,97 @@
+package thankAnalysis
+
+import (
+	"fmt"
+	"regexp"
+
+	scraper "language-analysis/scraper-src"
+)
+
+type TriggerSet struct {
+	Name     string
+	Patterns []string
+}
+
+var Config struct {
+	Triggers      []TriggerSet
+	Normalization string
+	Variants      map[string]string
+	Tokenizer     string
+}
+
+var analyzer scraper.Analyzer
+
+func ConfigureTokenizer() error {
+	a, err := scraper.MakeAnalyzer(Config.Tokenizer, Config.Normalization, Config.Variants)
+	if err != nil {
+		return err
+	}
+	analyzer = a
+	return nil
+}
+
+type trigger struct {
+	name    string
+	regexes []*regexp.Regexp
+}
+
+var triggers = []trigger{
+	{name: "thanks", regexes: []*regexp.Regexp{regexp.MustCompile(`\b[Tt]hank(s| you)\b`)}},
+}
+
+func ConfigureTriggers() error {
+	if len(Config.Triggers) == 0 {
+		return nil
+	}
+
+	configured := []trigger{}
+	names := map[string]bool{}
+	for _, set := range Config.Triggers {
+		if set.Name == "" {
+			return fmt.Errorf("Trigger set without a name")
+		}
+		if names[set.Name] {
+			return fmt.Errorf("Duplicate trigger set: %s", set.Name)
+		}
+		names[set.Name] = true
+		t := trigger{name: set.Name}
+		for _, pattern := range set.Patterns {
+			rx, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("Invalid pattern in trigger set %s: %v", set.Name, err)
+			}
+			t.regexes = append(t.regexes, rx)
+		}
+		configured = append(configured, t)
+	}
+	triggers = configured
+	return nil
+}
+
+func matchTriggers(text string) []string {
+	names := []string{}
+	for _, t := range triggers {
+		if t.match(text) {
+			names = append(names, t.name)
+		}
+	}
+	return names
+}
+
+func triggerByName(name string) (trigger, bool) {
+	for _, t := range triggers {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return trigger{}, false
+}
+
+func (t trigger) match(text string) bool {
+	for _, rx := range t.regexes {
+		if rx.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
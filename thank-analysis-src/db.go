@@ -3,19 +3,176 @@ package thankAnalysis
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 
+	attach "language-analysis/attach-src"
 	"language-analysis/config"
+	migrations "language-analysis/migrations-src"
+	report "language-analysis/report-src"
+	speakers "language-analysis/speakers-src"
 )
 
+var Schema = migrations.Database{
+	Name:     "thanks",
+	Filename: "thank-analysis.db",
+	Migrations: []migrations.Migration{
+		migrations.Migration{
+			Name:  "Create responses",
+			Probe: "SELECT lastFetchTimestamp FROM fetcherState LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE fetcherState (
+					lastFetchTimestamp TIMESTAMP)`,
+				`INSERT INTO fetcherState (lastFetchTimestamp)
+					VALUES ('1970-01-01 00:00:00')`,
+				`CREATE TABLE words (
+					wordID INTEGER PRIMARY KEY AUTOINCREMENT,
+					word TEXT UNIQUE)`,
+				`CREATE TABLE speakers (
+					speakerID INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT UNIQUE)`,
+				`CREATE INDEX speakersName ON speakers (name)`,
+				`CREATE TABLE files (
+					fileID INTEGER PRIMARY KEY,
+					date DATE)`,
+				`CREATE INDEX fileDate ON files (date)`,
+				`CREATE TABLE responses (
+					fileID INTEGER REFERENCES files (fileID),
+					speakerID INTEGER REFERENCES speakers (speakerID),
+					word1ID INTEGER REFERENCES words (wordID),
+					word2ID INTEGER REFERENCES words (wordID),
+					word3ID INTEGER REFERENCES words (wordID),
+					word4ID INTEGER REFERENCES words (wordID),
+					word5ID INTEGER REFERENCES words (wordID),
+					PRIMARY KEY (fileID, speakerID,
+						word1ID, word2ID, word3ID, word4ID, word5ID))`,
+				`CREATE INDEX responsesWords
+					ON responses (word1ID, word2ID, word3ID,
+							word4ID, word5ID)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add file speaker roles",
+			Probe: "SELECT role FROM fileSpeakers LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE fileSpeakers (
+					fileID INTEGER REFERENCES files (fileID),
+					speakerID INTEGER REFERENCES speakers (speakerID),
+					role TEXT,
+					PRIMARY KEY (fileID, speakerID))`,
+				`CREATE INDEX fileSpeakersRole ON fileSpeakers (role)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add speaker registry IDs",
+			Probe: "SELECT registryID FROM speakers LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE speakers ADD COLUMN registryID INTEGER`,
+				`CREATE INDEX speakersRegistryID ON speakers (registryID)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add exchanges",
+			Probe: "SELECT exchangeID FROM exchanges LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE exchanges (
+					exchangeID INTEGER PRIMARY KEY AUTOINCREMENT,
+					fileID INTEGER REFERENCES files (fileID),
+					thankIndex INTEGER,
+					responseIndex INTEGER,
+					thankerID INTEGER REFERENCES speakers (speakerID),
+					thankedID INTEGER REFERENCES speakers (speakerID),
+					responderID INTEGER REFERENCES speakers (speakerID),
+					distance INTEGER)`,
+				`CREATE INDEX exchangesFileID ON exchanges (fileID)`,
+				`CREATE TABLE exchangePhrases (
+					exchangeID INTEGER REFERENCES exchanges (exchangeID),
+					side TEXT,
+					word1ID INTEGER REFERENCES words (wordID),
+					word2ID INTEGER REFERENCES words (wordID),
+					word3ID INTEGER REFERENCES words (wordID),
+					word4ID INTEGER REFERENCES words (wordID),
+					word5ID INTEGER REFERENCES words (wordID),
+					PRIMARY KEY (exchangeID, side,
+						word1ID, word2ID, word3ID, word4ID, word5ID))`,
+				`CREATE INDEX exchangePhrasesWords
+					ON exchangePhrases (side, word1ID, word2ID, word3ID,
+							word4ID, word5ID)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add trigger names",
+			Probe: "SELECT triggerName FROM responses LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE responses ADD COLUMN triggerName TEXT NOT NULL DEFAULT 'thanks'`,
+				`CREATE INDEX responsesTriggerName ON responses (triggerName)`,
+				`ALTER TABLE exchanges ADD COLUMN triggerName TEXT NOT NULL DEFAULT 'thanks'`,
+				`CREATE INDEX exchangesTriggerName ON exchanges (triggerName)`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add file normalization",
+			Probe: "SELECT normalization FROM files LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE files ADD COLUMN normalization TEXT NOT NULL DEFAULT 'raw'`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add file tokenizer",
+			Probe: "SELECT tokenizer FROM files LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE files ADD COLUMN tokenizer TEXT NOT NULL DEFAULT 'compat'`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add all matching triggers",
+			Probe: "SELECT triggerName FROM responseTriggers LIMIT 1",
+			Statements: []string{
+				`CREATE TABLE responseTriggers (
+					fileID INTEGER REFERENCES files (fileID),
+					speakerID INTEGER REFERENCES speakers (speakerID),
+					triggerName TEXT,
+					PRIMARY KEY (fileID, speakerID, triggerName))`,
+				`CREATE INDEX responseTriggersTriggerName ON responseTriggers (triggerName)`,
+				`INSERT OR IGNORE INTO responseTriggers (fileID, speakerID, triggerName)
+					SELECT fileID, speakerID, triggerName FROM responses`,
+				`CREATE TABLE exchangeTriggers (
+					exchangeID INTEGER REFERENCES exchanges (exchangeID),
+					triggerName TEXT,
+					PRIMARY KEY (exchangeID, triggerName))`,
+				`CREATE INDEX exchangeTriggersTriggerName ON exchangeTriggers (triggerName)`,
+				`INSERT INTO exchangeTriggers (exchangeID, triggerName)
+					SELECT exchangeID, triggerName FROM exchanges`,
+			},
+		},
+		migrations.Migration{
+			Name:  "Add fetch cursor file IDs",
+			Probe: "SELECT lastFileID FROM fetcherState LIMIT 1",
+			Statements: []string{
+				`ALTER TABLE fetcherState ADD COLUMN lastFileID INTEGER NOT NULL DEFAULT 0`,
+				`UPDATE fetcherState SET lastFileID = (SELECT COALESCE(MAX(fileID), 0) FROM files)`,
+			},
+		},
+		migrations.Migration{
+			Name: "Drop single trigger names",
+			Statements: []string{
+				`DROP INDEX responsesTriggerName`,
+				`ALTER TABLE responses DROP COLUMN triggerName`,
+				`DROP INDEX exchangesTriggerName`,
+				`ALTER TABLE exchanges DROP COLUMN triggerName`,
+			},
+		},
+	},
+}
+
 type thankDB struct {
 	db *sql.DB
 }
 
 func openThankDB() (*thankDB, error) {
-	db, err := sql.Open("sqlite3", config.Dir()+"/thank-analysis.db")
+	db, err := sql.Open(attach.Driver, config.Dir()+"/"+Schema.Filename)
 	if err != nil {
 		return nil, err
 	}
@@ -33,52 +190,7 @@ func (db *thankDB) Close() error {
 }
 
 func (db *thankDB) init() error {
-	if _, err := db.db.Query("SELECT lastFetchTimestamp FROM fetcherState LIMIT 1"); err == nil {
-		return nil
-	}
-
-	tx, err := db.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	for _, statement := range []string{
-		`CREATE TABLE fetcherState (
-			lastFetchTimestamp TIMESTAMP)`,
-		`INSERT INTO fetcherState (lastFetchTimestamp)
-			VALUES ('1970-01-01 00:00:00')`,
-		`CREATE TABLE words (
-			wordID INTEGER PRIMARY KEY AUTOINCREMENT,
-			word TEXT UNIQUE)`,
-		`CREATE TABLE speakers (
-			speakerID INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE)`,
-		`CREATE INDEX speakersName ON speakers (name)`,
-		`CREATE TABLE files (
-			fileID INTEGER PRIMARY KEY,
-			date DATE)`,
-		`CREATE INDEX fileDate ON files (date)`,
-		`CREATE TABLE responses (
-			fileID INTEGER REFERENCES files (fileID),
-			speakerID INTEGER REFERENCES speakers (speakerID),
-			word1ID INTEGER REFERENCES words (wordID),
-			word2ID INTEGER REFERENCES words (wordID),
-			word3ID INTEGER REFERENCES words (wordID),
-			word4ID INTEGER REFERENCES words (wordID),
-			word5ID INTEGER REFERENCES words (wordID),
-			PRIMARY KEY (fileID, speakerID,
-				word1ID, word2ID, word3ID, word4ID, word5ID))`,
-		`CREATE INDEX responsesWords
-			ON responses (word1ID, word2ID, word3ID,
-					word4ID, word5ID)`,
-	} {
-		if _, err := tx.Exec(statement); err != nil {
-			return err
-		}
-	}
-
-	return tx.Commit()
+	return Schema.Apply(db.db)
 }
 
 func parseDate(dateString sql.NullString) time.Time {
@@ -91,73 +203,111 @@ func parseTimestamp(timestampString sql.NullString) time.Time {
 	return t
 }
 
-func (db *thankDB) lastFetchTimestamp() (time.Time, error) {
-	rows, err := db.db.Query("SELECT lastFetchTimestamp FROM fetcherState LIMIT 1")
+func (db *thankDB) fetchCursor() (time.Time, int64, error) {
+	rows, err := db.db.Query("SELECT lastFetchTimestamp, lastFileID FROM fetcherState LIMIT 1")
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, 0, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var fetchTimestamp sql.NullString
-		if err := rows.Scan(&fetchTimestamp); err != nil {
-			return time.Time{}, err
+		var fileID int64
+		if err := rows.Scan(&fetchTimestamp, &fileID); err != nil {
+			return time.Time{}, 0, err
 		}
-		return parseTimestamp(fetchTimestamp), nil
+		return parseTimestamp(fetchTimestamp), fileID, nil
 	}
 
 	tx, err := db.db.Begin()
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, 0, err
 	}
 	defer tx.Rollback()
 
 	if _, err := tx.Exec("INSERT INTO fetcherState (lastFetchTimestamp) VALUES ('1970-01-01 00:00:00')"); err != nil {
-		return time.Time{}, err
+		return time.Time{}, 0, err
 	}
 
-	return time.Time{}, tx.Commit()
+	return time.Time{}, 0, tx.Commit()
 }
 
-func (db *thankDB) setFetchTimestamp(lastFetchTimestamp time.Time) error {
+func (db *thankDB) setFetchCursor(lastFetchTimestamp time.Time, lastFileID int64) error {
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("UPDATE fetcherState SET lastFetchTimestamp = ?", lastFetchTimestamp.Format(time.DateTime)); err != nil {
+	if _, err := tx.Exec("UPDATE fetcherState SET lastFetchTimestamp = ?, lastFileID = ?", lastFetchTimestamp.Format(time.DateTime), lastFileID); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func (db *thankDB) addResponses(fileID int64, date time.Time, responses map[string]map[[5]string]bool) error {
+type response struct {
+	triggers []string
+	phrases  map[[MaxWords]string]bool
+}
+
+type exchange struct {
+	triggers        []string
+	thankIndex      int
+	responseIndex   int
+	thanker         string
+	thanked         string
+	responder       string
+	distance        int
+	thankPhrases    map[[MaxWords]string]bool
+	responsePhrases map[[MaxWords]string]bool
+}
+
+func (db *thankDB) addResponses(fileID int64, date time.Time, normalization, tokenizer string, roles map[string]string, registryIDs map[string]int64, responses map[string]response, exchanges []exchange) error {
 	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("INSERT INTO files (fileID, date) VALUES (?,?)", fileID, date.Format(time.DateOnly)); err != nil {
+	if _, err := tx.Exec("INSERT INTO files (fileID, date, normalization, tokenizer) VALUES (?,?,?,?)", fileID, date.Format(time.DateOnly), normalization, tokenizer); err != nil {
 		return err
 	}
 
+	for speaker, role := range roles {
+		speakerID, err := db.getSpeakerID(tx, speaker)
+		if err != nil {
+			return err
+		}
+		if registryID, ok := registryIDs[speaker]; ok {
+			if _, err := tx.Exec("UPDATE speakers SET registryID = ? WHERE speakerID = ?", registryID, speakerID); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO fileSpeakers (fileID, speakerID, role) VALUES (?,?,?)", fileID, speakerID, role); err != nil {
+			return err
+		}
+	}
+
 	wordIDs := map[string]int64{}
 	speakerWordIDs := [][6]int64{}
-	for speaker, responsePhrases := range responses {
+	for speaker, resp := range responses {
 		speakerID, err := db.getSpeakerID(tx, speaker)
 		if err != nil {
 			return err
 		}
-		for responsePhrase := range responsePhrases {
+		for responsePhrase := range resp.phrases {
 			speakerWordID, err := db.getSpeakerWordID(tx, speakerID, responsePhrase, wordIDs)
 			if err != nil {
 				return err
 			}
 			speakerWordIDs = append(speakerWordIDs, speakerWordID)
 		}
+		for _, trigger := range resp.triggers {
+			if _, err := tx.Exec("INSERT INTO responseTriggers (fileID, speakerID, triggerName) VALUES (?,?,?)", fileID, speakerID, trigger); err != nil {
+				return err
+			}
+		}
 	}
 
 	for _, speakerWordID := range speakerWordIDs {
@@ -166,9 +316,54 @@ func (db *thankDB) addResponses(fileID int64, date time.Time, responses map[stri
 		}
 	}
 
+	for _, e := range exchanges {
+		if err := db.addExchange(tx, fileID, e, wordIDs); err != nil {
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
+func (db *thankDB) addExchange(tx *sql.Tx, fileID int64, e exchange, wordIDs map[string]int64) error {
+	speakerIDs := [3]int64{}
+	for i, speaker := range []string{e.thanker, e.thanked, e.responder} {
+		speakerID, err := db.getSpeakerID(tx, speaker)
+		if err != nil {
+			return err
+		}
+		speakerIDs[i] = speakerID
+	}
+
+	result, err := tx.Exec("INSERT INTO exchanges (fileID, thankIndex, responseIndex, thankerID, thankedID, responderID, distance) VALUES (?,?,?,?,?,?,?)", fileID, e.thankIndex, e.responseIndex, speakerIDs[0], speakerIDs[1], speakerIDs[2], e.distance)
+	if err != nil {
+		return err
+	}
+	exchangeID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, trigger := range e.triggers {
+		if _, err := tx.Exec("INSERT INTO exchangeTriggers (exchangeID, triggerName) VALUES (?,?)", exchangeID, trigger); err != nil {
+			return err
+		}
+	}
+
+	for side, phrases := range map[string]map[[MaxWords]string]bool{"thank": e.thankPhrases, "response": e.responsePhrases} {
+		for phrase := range phrases {
+			phraseWordIDs, err := db.getPhraseWordIDs(tx, phrase, wordIDs)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("INSERT INTO exchangePhrases (exchangeID, side, word1ID, word2ID, word3ID, word4ID, word5ID) VALUES (?,?,?,?,?,?,?)", exchangeID, side, phraseWordIDs[0], phraseWordIDs[1], phraseWordIDs[2], phraseWordIDs[3], phraseWordIDs[4]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (db *thankDB) getSpeakerID(tx *sql.Tx, speaker string) (int64, error) {
 	for range 2 {
 		rows, err := tx.Query("SELECT speakerID FROM speakers WHERE name = ?", speaker)
@@ -196,16 +391,72 @@ func (db *thankDB) getSpeakerID(tx *sql.Tx, speaker string) (int64, error) {
 	return 0, fmt.Errorf("Failed to get speakerID for %s", speaker)
 }
 
+func (db *thankDB) syncSpeakers() error {
+	rows, err := db.db.Query("SELECT name, registryID FROM speakers WHERE name IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	current := map[string]int64{}
+	for rows.Next() {
+		var name string
+		var registryID sql.NullInt64
+		if err := rows.Scan(&name, &registryID); err != nil {
+			return err
+		}
+		names = append(names, name)
+		current[name] = registryID.Int64
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	registryIDs, err := speakers.Resolve(names)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for name, registryID := range registryIDs {
+		if current[name] == registryID {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE speakers SET registryID = ? WHERE name = ?", registryID, name); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (db *thankDB) getSpeakerWordID(tx *sql.Tx, speakerID int64, responsePhrase [5]string, wordIDs map[string]int64) ([6]int64, error) {
-	speakerWordID := [6]int64{speakerID, 0, 0, 0, 0, 0}
-	for i, word := range responsePhrase {
+	phraseWordIDs, err := db.getPhraseWordIDs(tx, responsePhrase, wordIDs)
+	if err != nil {
+		return [6]int64{}, err
+	}
+	speakerWordID := [6]int64{speakerID}
+	copy(speakerWordID[1:], phraseWordIDs[:])
+	return speakerWordID, nil
+}
+
+func (db *thankDB) getPhraseWordIDs(tx *sql.Tx, phrase [MaxWords]string, wordIDs map[string]int64) ([MaxWords]int64, error) {
+	phraseWordIDs := [MaxWords]int64{}
+	for i, word := range phrase {
 		wordID, err := db.getWordID(tx, word, wordIDs)
 		if err != nil {
-			return [6]int64{}, err
+			return [MaxWords]int64{}, err
 		}
-		speakerWordID[i+1] = wordID
+		phraseWordIDs[i] = wordID
 	}
-	return speakerWordID, nil
+	return phraseWordIDs, nil
 }
 
 func (db *thankDB) getWordID(tx *sql.Tx, word string, wordIDs map[string]int64) (int64, error) {
@@ -239,3 +490,260 @@ func (db *thankDB) getWordID(tx *sql.Tx, word string, wordIDs map[string]int64)
 	}
 	return 0, fmt.Errorf("Failed to get wordID for %s", word)
 }
+
+type responseCount struct {
+	period string
+	phrase [MaxWords]string
+	count  int
+}
+
+type responseFilter struct {
+	role          string
+	registryID    int64
+	feedID        int64
+	trigger       string
+	normalization string
+	tokenizer     string
+	from          time.Time
+	to            time.Time
+}
+
+func (filter responseFilter) where() (string, []any) {
+	conditions := []string{}
+	args := []any{}
+	if filter.role != "" {
+		conditions = append(conditions, "responses.speakerID IN (SELECT speakerID FROM fileSpeakers WHERE fileSpeakers.fileID = responses.fileID AND fileSpeakers.role = ?)")
+		args = append(args, filter.role)
+	}
+	if filter.registryID != 0 {
+		conditions = append(conditions, "responses.speakerID IN (SELECT speakerID FROM speakers WHERE registryID = ?)")
+		args = append(args, filter.registryID)
+	}
+	if filter.feedID != 0 {
+		conditions = append(conditions, "responses.fileID IN (SELECT fileID FROM fetcher.files WHERE feedID = ?)")
+		args = append(args, filter.feedID)
+	}
+	if filter.trigger != "" {
+		conditions = append(conditions, "(responses.fileID, responses.speakerID) IN (SELECT fileID, speakerID FROM responseTriggers WHERE triggerName = ?)")
+		args = append(args, filter.trigger)
+	}
+	if filter.normalization != "" {
+		conditions = append(conditions, "responses.fileID IN (SELECT fileID FROM files WHERE normalization = ?)")
+		args = append(args, filter.normalization)
+	}
+	if filter.tokenizer != "" {
+		conditions = append(conditions, "responses.fileID IN (SELECT fileID FROM files WHERE tokenizer = ?)")
+		args = append(args, filter.tokenizer)
+	}
+	if !filter.from.IsZero() {
+		conditions = append(conditions, "responses.fileID IN (SELECT fileID FROM files WHERE date >= ?)")
+		args = append(args, filter.from.Format(time.DateOnly))
+	}
+	if !filter.to.IsZero() {
+		conditions = append(conditions, "responses.fileID IN (SELECT fileID FROM files WHERE date <= ?)")
+		args = append(args, filter.to.Format(time.DateOnly))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (db *thankDB) responseCounts(period string, filter responseFilter) ([]responseCount, error) {
+	periodExpr, err := report.PeriodExpression(period, "files.date")
+	if err != nil {
+		return nil, err
+	}
+	filterWhere, args := filter.where()
+
+	rows, err := db.db.Query(`SELECT `+periodExpr+` AS period, w1.word, w2.word, w3.word, w4.word, w5.word, COUNT(*)
+		FROM responses
+		JOIN files ON files.fileID = responses.fileID
+		JOIN words w1 ON w1.wordID = responses.word1ID
+		JOIN words w2 ON w2.wordID = responses.word2ID
+		JOIN words w3 ON w3.wordID = responses.word3ID
+		JOIN words w4 ON w4.wordID = responses.word4ID
+		JOIN words w5 ON w5.wordID = responses.word5ID
+		`+filterWhere+`
+		GROUP BY period, responses.word1ID, responses.word2ID, responses.word3ID, responses.word4ID, responses.word5ID
+		ORDER BY period`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []responseCount{}
+	for rows.Next() {
+		count := responseCount{}
+		if err := rows.Scan(&count.period, &count.phrase[0], &count.phrase[1], &count.phrase[2], &count.phrase[3], &count.phrase[4], &count.count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+func (db *thankDB) exportResponses(filter responseFilter, write func(values ...any) error) error {
+	filterWhere, args := filter.where()
+
+	attached, err := attach.Attached(db.db, "fetcher")
+	if err != nil {
+		return err
+	}
+	feedColumn, feedJoin := "NULL", ""
+	if attached {
+		feedColumn, feedJoin = "feedFiles.feedID", "LEFT JOIN fetcher.files feedFiles ON feedFiles.fileID = responses.fileID"
+	}
+
+	rows, err := db.db.Query(`SELECT files.date, responses.fileID, `+feedColumn+`, speakers.name,
+			w1.word, w2.word, w3.word, w4.word, w5.word
+		FROM responses
+		JOIN files ON files.fileID = responses.fileID
+		JOIN speakers ON speakers.speakerID = responses.speakerID
+		`+feedJoin+`
+		JOIN words w1 ON w1.wordID = responses.word1ID
+		JOIN words w2 ON w2.wordID = responses.word2ID
+		JOIN words w3 ON w3.wordID = responses.word3ID
+		JOIN words w4 ON w4.wordID = responses.word4ID
+		JOIN words w5 ON w5.wordID = responses.word5ID
+		`+filterWhere+`
+		ORDER BY files.date, responses.fileID, speakers.name`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date sql.NullString
+		var fileID int64
+		var feedID sql.NullInt64
+		var speaker string
+		var phrase [MaxWords]string
+		if err := rows.Scan(&date, &fileID, &feedID, &speaker, &phrase[0], &phrase[1], &phrase[2], &phrase[3], &phrase[4]); err != nil {
+			return err
+		}
+		var feed any
+		if feedID.Valid {
+			feed = feedID.Int64
+		}
+		if ngram := phraseString(phrase); ngram != "" {
+			if err := write(parseDate(date), fileID, feed, speaker, ngram); err != nil {
+				return err
+			}
+		}
+	}
+	return rows.Err()
+}
+
+func (db *thankDB) responseTotals(period string, filter responseFilter) (map[string]int, error) {
+	periodExpr, err := report.PeriodExpression(period, "files.date")
+	if err != nil {
+		return nil, err
+	}
+	filterWhere, args := filter.where()
+
+	rows, err := db.db.Query(`SELECT period, COUNT(*) FROM (
+			SELECT DISTINCT `+periodExpr+` AS period, responses.fileID, responses.speakerID
+			FROM responses
+			JOIN files ON files.fileID = responses.fileID
+			`+filterWhere+`)
+		GROUP BY period`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := map[string]int{}
+	for rows.Next() {
+		var period string
+		var total int
+		if err := rows.Scan(&period, &total); err != nil {
+			return nil, err
+		}
+		totals[period] = total
+	}
+	return totals, rows.Err()
+}
+
+type exchangeCount struct {
+	phrase   [MaxWords]string
+	count    int
+	distance float64
+}
+
+func (db *thankDB) phraseWordIDs(phrase [MaxWords]string) ([MaxWords]int64, bool, error) {
+	wordIDs := [MaxWords]int64{}
+	for i, word := range phrase {
+		if err := db.db.QueryRow("SELECT wordID FROM words WHERE word = ?", word).Scan(&wordIDs[i]); err == sql.ErrNoRows {
+			return wordIDs, false, nil
+		} else if err != nil {
+			return wordIDs, false, err
+		}
+	}
+	return wordIDs, true, nil
+}
+
+func (db *thankDB) exchangeCounts(side string, filter responseFilter, filters map[string][MaxWords]string) ([]exchangeCount, int, error) {
+	conditions := []string{"1"}
+	args := []any{}
+	if filter.feedID != 0 {
+		conditions = append(conditions, "exchanges.fileID IN (SELECT fileID FROM fetcher.files WHERE feedID = ?)")
+		args = append(args, filter.feedID)
+	}
+	if filter.trigger != "" {
+		conditions = append(conditions, "exchanges.exchangeID IN (SELECT exchangeID FROM exchangeTriggers WHERE triggerName = ?)")
+		args = append(args, filter.trigger)
+	}
+	if filter.normalization != "" {
+		conditions = append(conditions, "exchanges.fileID IN (SELECT fileID FROM files WHERE normalization = ?)")
+		args = append(args, filter.normalization)
+	}
+	if filter.tokenizer != "" {
+		conditions = append(conditions, "exchanges.fileID IN (SELECT fileID FROM files WHERE tokenizer = ?)")
+		args = append(args, filter.tokenizer)
+	}
+	for filterSide, phrase := range filters {
+		wordIDs, ok, err := db.phraseWordIDs(phrase)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			return nil, 0, nil
+		}
+		conditions = append(conditions, `exchanges.exchangeID IN (SELECT exchangeID FROM exchangePhrases
+			WHERE side = ? AND word1ID = ? AND word2ID = ? AND word3ID = ? AND word4ID = ? AND word5ID = ?)`)
+		args = append(args, filterSide, wordIDs[0], wordIDs[1], wordIDs[2], wordIDs[3], wordIDs[4])
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := db.db.QueryRow("SELECT COUNT(*) FROM exchanges WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.db.Query(`SELECT w1.word, w2.word, w3.word, w4.word, w5.word, COUNT(*), AVG(exchanges.distance)
+		FROM exchanges
+		JOIN exchangePhrases ON exchangePhrases.exchangeID = exchanges.exchangeID AND exchangePhrases.side = ?
+		JOIN words w1 ON w1.wordID = exchangePhrases.word1ID
+		JOIN words w2 ON w2.wordID = exchangePhrases.word2ID
+		JOIN words w3 ON w3.wordID = exchangePhrases.word3ID
+		JOIN words w4 ON w4.wordID = exchangePhrases.word4ID
+		JOIN words w5 ON w5.wordID = exchangePhrases.word5ID
+		WHERE `+where+`
+		GROUP BY exchangePhrases.word1ID, exchangePhrases.word2ID, exchangePhrases.word3ID, exchangePhrases.word4ID, exchangePhrases.word5ID`,
+		append([]any{side}, args...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	counts := []exchangeCount{}
+	for rows.Next() {
+		count := exchangeCount{}
+		if err := rows.Scan(&count.phrase[0], &count.phrase[1], &count.phrase[2], &count.phrase[3], &count.phrase[4], &count.count, &count.distance); err != nil {
+			return nil, 0, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, total, rows.Err()
+}
@@ -0,0 +1,128 @@
+package thankAnalysis
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	scraper "language-analysis/scraper-src"
+)
+
+type Exchange struct {
+	Triggers []string
+	Thank    scraper.Transcript
+	Thanked  string
+	Response scraper.Transcript
+	Distance int
+}
+
+func Exchanges(transcript []scraper.Transcript) []Exchange {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, ts := range transcript {
+		if ts.Name != "" && !seen[ts.Name] {
+			seen[ts.Name] = true
+			names = append(names, ts.Name)
+		}
+	}
+
+	exchanges := []Exchange{}
+	for i, ts := range transcript {
+		if ts.Name == "" {
+			continue
+		}
+		triggers := matchTriggers(ts.Text)
+		if len(triggers) == 0 {
+			continue
+		}
+		for j := i + 1; j < len(transcript); j++ {
+			if transcript[j].Name == "" || transcript[j].Name == ts.Name {
+				continue
+			}
+			exchange := Exchange{
+				Triggers: triggers,
+				Thank:    ts,
+				Thanked:  thankedName(ts, names),
+				Response: transcript[j],
+				Distance: j - i,
+			}
+			if exchange.Thanked == "" {
+				exchange.Thanked = transcript[j].Name
+			}
+			exchanges = append(exchanges, exchange)
+			break
+		}
+	}
+	return exchanges
+}
+
+func thankedName(thank scraper.Transcript, names []string) string {
+	text := strings.ToUpper(thank.Text)
+	for _, name := range names {
+		if name == thank.Name {
+			continue
+		}
+		for _, word := range strings.Fields(name) {
+			if len(word) > 2 && wordRegex(word).MatchString(text) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+var wordRegexes = struct {
+	sync.Mutex
+	rxs map[string]*regexp.Regexp
+}{
+	rxs: map[string]*regexp.Regexp{},
+}
+
+func wordRegex(word string) *regexp.Regexp {
+	wordRegexes.Lock()
+	defer wordRegexes.Unlock()
+
+	if rx, ok := wordRegexes.rxs[word]; ok {
+		return rx
+	}
+	rx := regexp.MustCompile(`\b` + regexp.QuoteMeta(word) + `\b`)
+	wordRegexes.rxs[word] = rx
+	return rx
+}
+
+func ThankSentences(text string, triggerNames ...string) []string {
+	ts := []trigger{}
+	for _, name := range triggerNames {
+		if t, ok := triggerByName(name); ok {
+			ts = append(ts, t)
+		}
+	}
+	sentences := []string{}
+	start := -1
+	for _, token := range analyzer.Tokenize(text) {
+		if start < 0 {
+			start = token.Start
+		}
+		if token.SentenceEnd {
+			sentence := strings.TrimSpace(text[start:token.End])
+			for _, t := range ts {
+				if t.match(sentence) {
+					sentences = append(sentences, sentence)
+					break
+				}
+			}
+			start = -1
+		}
+	}
+	return sentences
+}
+
+func ThankPhrases(text string, triggerNames ...string) map[[MaxWords]string]bool {
+	phrases := map[[MaxWords]string]bool{}
+	for _, sentence := range ThankSentences(text, triggerNames...) {
+		for phrase := range textPhrases(sentence, 0) {
+			phrases[phrase] = true
+		}
+	}
+	return phrases
+}
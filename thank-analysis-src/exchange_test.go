@@ -0,0 +1,64 @@
+package thankAnalysis
+
+import (
+	"reflect"
+	"testing"
+
+	scraper "language-analysis/scraper-src"
+)
+
+func TestThankSentences(t *testing.T) {
+	defer func(saved scraper.Analyzer) { analyzer = saved }(analyzer)
+	tests := []struct {
+		text   string
+		want   []string
+		compat []string
+	}{
+		{"Jane Smith, thanks for joining us", []string{"Jane Smith, thanks for joining us"}, nil},
+		{"That's all. Thanks for joining us ", []string{"Thanks for joining us"}, nil},
+		{"Thanks, Jane. We'll be right back.", []string{"Thanks, Jane"}, []string{"Thanks, Jane."}},
+	}
+	for _, tokenizer := range []string{"sentences", "compat"} {
+		analyzer, _ = scraper.MakeAnalyzer(tokenizer, "", nil)
+		for _, test := range tests {
+			want := test.want
+			if tokenizer == "compat" && test.compat != nil {
+				want = test.compat
+			}
+			if got := ThankSentences(test.text, "thanks"); !reflect.DeepEqual(got, want) {
+				t.Errorf("ThankSentences(%q) with %s = %q, want %q", test.text, tokenizer, got, want)
+			}
+		}
+	}
+}
+
+func TestAllMatchingTriggers(t *testing.T) {
+	defer func(saved []trigger) { triggers = saved }(triggers)
+	Config.Triggers = []TriggerSet{
+		{Name: "thanks", Patterns: []string{`\b[Tt]hank(s| you)\b`}},
+		{Name: "joining", Patterns: []string{`\bfor joining us\b`}},
+		{Name: "appreciation", Patterns: []string{`\b[Aa]ppreciate it\b`}},
+	}
+	defer func() { Config.Triggers = nil }()
+	if err := ConfigureTriggers(); err != nil {
+		t.Fatal(err)
+	}
+
+	transcript := []scraper.Transcript{
+		{Index: 0, Name: "HOST", Text: "Jane Smith, thanks for joining us. We appreciate it."},
+		{Index: 1, Name: "JANE SMITH", Text: "You bet."},
+	}
+	want := []string{"thanks", "joining", "appreciation"}
+	resps := ThankResponses(transcript)
+	if len(resps) != 1 || !reflect.DeepEqual(resps[0].Triggers, want) {
+		t.Errorf("ThankResponses = %+v, want triggers %q", resps, want)
+	}
+	exchanges := Exchanges(transcript)
+	if len(exchanges) != 1 || !reflect.DeepEqual(exchanges[0].Triggers, want) {
+		t.Fatalf("Exchanges = %+v, want triggers %q", exchanges, want)
+	}
+	sentences := ThankSentences(transcript[0].Text, exchanges[0].Triggers...)
+	if want := []string{"Jane Smith, thanks for joining us", "We appreciate it"}; !reflect.DeepEqual(sentences, want) {
+		t.Errorf("ThankSentences = %q, want %q", sentences, want)
+	}
+}
@@ -0,0 +1,223 @@
+package thankAnalysis
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	attach "language-analysis/attach-src"
+	"language-analysis/config"
+	speakers "language-analysis/speakers-src"
+)
+
+func ReportCommand() error {
+	period := config.String("report-period")
+	format := config.String("report-format")
+	tokenizer, normalization, err := analyzer.ReportFilters(config.String("report-tokenizer"), config.String("report-normalization"))
+	if err != nil {
+		return config.Usagef("Invalid report filter: %v", err)
+	}
+	filter := responseFilter{
+		role:          config.String("report-role"),
+		feedID:        int64(config.Int("report-feed")),
+		trigger:       config.String("report-trigger"),
+		normalization: normalization,
+		tokenizer:     tokenizer,
+	}
+	top := config.Int("report-top")
+	minCount := config.Int("report-min-count")
+
+	if speaker := config.String("report-speaker"); speaker != "" {
+		s, err := speakers.Find(speaker)
+		if err != nil {
+			return err
+		}
+		filter.registryID = s.ID()
+	}
+
+	db, err := openThankDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if filter.feedID != 0 {
+		if err := attach.Require(db.db, "fetcher"); err != nil {
+			return err
+		}
+	}
+
+	if filter.registryID != 0 {
+		if err := db.syncSpeakers(); err != nil {
+			return err
+		}
+	}
+
+	counts, err := db.responseCounts(period, filter)
+	if err != nil {
+		return err
+	}
+	totals, err := db.responseTotals(period, filter)
+	if err != nil {
+		return err
+	}
+
+	phraseTotals := map[string]int{}
+	for _, count := range counts {
+		phraseTotals[phraseString(count.phrase)] += count.count
+	}
+	selected := []string{}
+	for phrase, total := range phraseTotals {
+		if phrase != "" && total >= minCount {
+			selected = append(selected, phrase)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if phraseTotals[selected[i]] != phraseTotals[selected[j]] {
+			return phraseTotals[selected[i]] > phraseTotals[selected[j]]
+		}
+		return selected[i] < selected[j]
+	})
+	if top > 0 && len(selected) > top {
+		selected = selected[:top]
+	}
+	isSelected := map[string]bool{}
+	for _, phrase := range selected {
+		isSelected[phrase] = true
+	}
+
+	rows := []responseCount{}
+	for _, count := range counts {
+		if isSelected[phraseString(count.phrase)] {
+			rows = append(rows, count)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].period != rows[j].period {
+			return rows[i].period < rows[j].period
+		}
+		return rows[i].count > rows[j].count
+	})
+
+	if format == "csv" {
+		out := csv.NewWriter(os.Stdout)
+		out.Write([]string{"period", "response", "count", "total", "share"})
+		for _, row := range rows {
+			total := totals[row.period]
+			out.Write([]string{row.period, phraseString(row.phrase), fmt.Sprintf("%d", row.count), fmt.Sprintf("%d", total), fmt.Sprintf("%.4f", share(row.count, total))})
+		}
+		out.Flush()
+		return out.Error()
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(out, "period\tresponse\tcount\ttotal\tshare\t\n")
+	for _, row := range rows {
+		total := totals[row.period]
+		fmt.Fprintf(out, "%s\t%s\t%d\t%d\t%.1f%%\t\n", row.period, phraseString(row.phrase), row.count, total, 100*share(row.count, total))
+	}
+	return out.Flush()
+}
+
+func phraseString(phrase [MaxWords]string) string {
+	words := []string{}
+	for _, word := range phrase {
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func share(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+func ExchangesCommand() error {
+	side := config.String("exchange-side")
+	format := config.String("report-format")
+	top := config.Int("report-top")
+	minCount := config.Int("report-min-count")
+
+	filters := map[string][MaxWords]string{}
+	for _, filterSide := range []string{"thank", "response"} {
+		if phrase := config.String("exchange-" + filterSide); phrase != "" {
+			words := []string{}
+			for _, sentence := range analyzer.Sentences(phrase) {
+				words = append(words, sentence...)
+			}
+			if len(words) > MaxWords {
+				return fmt.Errorf("Exchange %s phrase longer than %d words: %s", filterSide, MaxWords, phrase)
+			}
+			filter := [MaxWords]string{}
+			copy(filter[:], words)
+			filters[filterSide] = filter
+		}
+	}
+
+	db, err := openThankDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tokenizer, normalization, err := analyzer.ReportFilters(config.String("report-tokenizer"), config.String("report-normalization"))
+	if err != nil {
+		return config.Usagef("Invalid report filter: %v", err)
+	}
+	filter := responseFilter{
+		feedID:        int64(config.Int("report-feed")),
+		trigger:       config.String("report-trigger"),
+		normalization: normalization,
+		tokenizer:     tokenizer,
+	}
+	if filter.feedID != 0 {
+		if err := attach.Require(db.db, "fetcher"); err != nil {
+			return err
+		}
+	}
+
+	counts, total, err := db.exchangeCounts(side, filter, filters)
+	if err != nil {
+		return err
+	}
+
+	rows := []exchangeCount{}
+	for _, count := range counts {
+		if phraseString(count.phrase) != "" && count.count >= minCount {
+			rows = append(rows, count)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].count != rows[j].count {
+			return rows[i].count > rows[j].count
+		}
+		return phraseString(rows[i].phrase) < phraseString(rows[j].phrase)
+	})
+	if top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+
+	if format == "csv" {
+		out := csv.NewWriter(os.Stdout)
+		out.Write([]string{side, "count", "total", "share", "distance"})
+		for _, row := range rows {
+			out.Write([]string{phraseString(row.phrase), fmt.Sprintf("%d", row.count), fmt.Sprintf("%d", total), fmt.Sprintf("%.4f", share(row.count, total)), fmt.Sprintf("%.2f", row.distance)})
+		}
+		out.Flush()
+		return out.Error()
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(out, "%s\tcount\ttotal\tshare\tdistance\t\n", side)
+	for _, row := range rows {
+		fmt.Fprintf(out, "%s\t%d\t%d\t%.1f%%\t%.2f\t\n", phraseString(row.phrase), row.count, total, 100*share(row.count, total), row.distance)
+	}
+	return out.Flush()
+}
@@ -1,41 +1,49 @@
 package thankAnalysis
 
 import (
-	"regexp"
-
 	scraper "language-analysis/scraper-src"
 )
 
 const MaxWords = 5
 
-var thanksRegex = regexp.MustCompile(`\b[Tt]hank(s| you)\b`)
+type Response struct {
+	scraper.Transcript
+	Triggers []string
+}
 
-func ThankResponses(transcript []scraper.Transcript) []scraper.Transcript {
-	resps := map[string]scraper.Transcript{}
-	lastWasThanks := false
+func ThankResponses(transcript []scraper.Transcript) []Response {
+	resps := map[string]Response{}
+	lastTriggers := []string{}
 	saidThanks := map[string]bool{}
 	for _, ts := range transcript {
-		if lastWasThanks && ts.Name != "" && !saidThanks[ts.Name] {
-			resps[ts.Name] = ts
+		if len(lastTriggers) > 0 && ts.Name != "" && !saidThanks[ts.Name] {
+			resps[ts.Name] = Response{ts, lastTriggers}
 		} else {
 			delete(resps, ts.Name)
 		}
-		lastWasThanks = thanksRegex.MatchString(ts.Text)
-		saidThanks[ts.Name] = lastWasThanks
+		lastTriggers = matchTriggers(ts.Text)
+		saidThanks[ts.Name] = len(lastTriggers) > 0
 	}
-	results := []scraper.Transcript{}
-	for _, ts := range resps {
-		results = append(results, ts)
+	results := []Response{}
+	for _, resp := range resps {
+		results = append(results, resp)
 	}
 	return results
 }
 
 func ResponsePhrases(text string) map[[MaxWords]string]bool {
+	return textPhrases(text, 20)
+}
+
+func textPhrases(text string, firstWords int) map[[MaxWords]string]bool {
 	phrases := map[[MaxWords]string]bool{}
 
 	phrase := [MaxWords]string{}
-	phraser := scraper.MakePhraser(MaxWords, text)
-	phraser.OnlyFirstWords(20)
+	phraser := scraper.MakeTokenPhraser(MaxWords, analyzer.Tokenize(text))
+	if firstWords > 0 {
+		phraser.OnlyFirstWords(firstWords)
+	}
+	phraser.Normalize(analyzer.Normalizer())
 	for p := phraser.Next(); p != nil; p = phraser.Next() {
 		for i := range MaxWords {
 			phrase[i] = ""